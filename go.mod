@@ -3,6 +3,7 @@ module github.com/grafana-labs/surrealdb-datasource
 go 1.24.6
 
 require (
+	github.com/gorilla/websocket v1.5.0
 	github.com/grafana/grafana-plugin-sdk-go v0.283.0
 	github.com/surrealdb/surrealdb.go v0.2.1
 )
@@ -25,7 +26,6 @@ require (
 	github.com/google/flatbuffers v25.2.10+incompatible // indirect
 	github.com/google/go-cmp v0.7.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
-	github.com/gorilla/websocket v1.5.0 // indirect
 	github.com/grafana/otel-profiling-go v0.5.1 // indirect
 	github.com/grafana/pyroscope-go/godeltaprof v0.1.9 // indirect
 	github.com/grpc-ecosystem/go-grpc-middleware/providers/prometheus v1.1.0 // indirect
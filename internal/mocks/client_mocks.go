@@ -1,12 +1,21 @@
 package mocks
 
+import "github.com/grafana-labs/surrealdb-datasource/pkg/client"
+
 // MockDBClient is a mock implementation of the SurrealDBClient interface.
 type MockSurrealDBClient struct {
-	CloseFunc  func()
-	CreateFunc func(thing string, data interface{}) (interface{}, error)
-	QueryFunc  func(sql string, vars interface{}) (interface{}, error)
-	SigninFunc func(vars interface{}) (interface{}, error)
-	UseFunc    func(namespace string, database string) (interface{}, error)
+	AuthenticateFunc  func(token string) (interface{}, error)
+	CloseFunc         func()
+	CreateFunc        func(thing string, data interface{}) (interface{}, error)
+	KillFunc          func(id string) (interface{}, error)
+	NotificationsFunc func(id string) (<-chan client.Notification, error)
+	QueryFunc         func(sql string, vars interface{}) (interface{}, error)
+	SigninFunc        func(vars interface{}) (interface{}, error)
+	UseFunc           func(namespace string, database string) (interface{}, error)
+}
+
+func (m *MockSurrealDBClient) Authenticate(token string) (interface{}, error) {
+	return m.AuthenticateFunc(token)
 }
 
 func (m *MockSurrealDBClient) Close() {
@@ -28,3 +37,11 @@ func (m *MockSurrealDBClient) Use(namespace string, database string) (interface{
 func (m *MockSurrealDBClient) Create(thing string, data interface{}) (interface{}, error) {
 	return m.CreateFunc(thing, data)
 }
+
+func (m *MockSurrealDBClient) Kill(id string) (interface{}, error) {
+	return m.KillFunc(id)
+}
+
+func (m *MockSurrealDBClient) Notifications(id string) (<-chan client.Notification, error) {
+	return m.NotificationsFunc(id)
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AuthMode defines how the client authenticates with the database.
+type AuthMode string
+
+const (
+	// AuthModeRoot signs in as a root user with a username and password, or as a
+	// scope user when a scope is set. It is the default mode.
+	AuthModeRoot AuthMode = "root"
+	// AuthModeNamespace signs in as a user defined on the namespace.
+	AuthModeNamespace AuthMode = "namespace"
+	// AuthModeDatabase signs in as a user defined on the database.
+	AuthModeDatabase AuthMode = "database"
+	// AuthModeRecord signs in as a record user with the record access method
+	// and signin variables of the config (SurrealDB 2.x).
+	AuthModeRecord AuthMode = "record"
+	// AuthModeToken authenticates with a pre-issued JWT token.
+	AuthModeToken AuthMode = "token"
+	// AuthModeAnonymous does not authenticate at all.
+	AuthModeAnonymous AuthMode = "anonymous"
+)
+
+// errNoToken is returned when the token mode is used without a token.
+var errNoToken = errors.New("token authentication requires a token")
+
+// authenticate signs in or authenticates db according to the auth mode of config.
+func authenticate(db SurrealDBClient, config *SurrealConfig) error {
+	switch config.AuthMode {
+	case AuthModeAnonymous:
+		return nil
+	case AuthModeToken:
+		if config.Token == "" {
+			return errNoToken
+		}
+		_, err := db.Authenticate(config.Token)
+		return err
+	}
+
+	vars, err := signinVars(config)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Signin(vars)
+	return err
+}
+
+// signinVars returns the variables sent to sign in with the auth mode of config.
+func signinVars(config *SurrealConfig) (map[string]interface{}, error) {
+	switch config.AuthMode {
+	case "", AuthModeRoot:
+		vars := map[string]interface{}{
+			"user": config.Username,
+			"pass": config.Password,
+		}
+		if config.Scope != "" {
+			vars["scope"] = config.Scope
+		}
+		return vars, nil
+	case AuthModeNamespace:
+		return map[string]interface{}{
+			"NS":   config.Namespace,
+			"user": config.Username,
+			"pass": config.Password,
+		}, nil
+	case AuthModeDatabase:
+		return map[string]interface{}{
+			"NS":   config.Namespace,
+			"DB":   config.Database,
+			"user": config.Username,
+			"pass": config.Password,
+		}, nil
+	case AuthModeRecord:
+		if config.Access == "" {
+			return nil, errors.New("record authentication requires an access method")
+		}
+		vars := make(map[string]interface{}, len(config.AccessVariables)+3)
+		for k, v := range config.AccessVariables {
+			vars[k] = v
+		}
+		vars["NS"] = config.Namespace
+		vars["DB"] = config.Database
+		vars["AC"] = config.Access
+		return vars, nil
+	default:
+		return nil, fmt.Errorf("unknown auth mode %q", config.AuthMode)
+	}
+}
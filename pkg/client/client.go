@@ -1,6 +1,21 @@
 package client
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana-labs/surrealdb-datasource/pkg/surrealql"
+	"github.com/surrealdb/surrealdb.go"
+)
+
+// ErrLiveUnsupported is returned when the connection does not deliver live query notifications.
+var ErrLiveUnsupported = errors.New("live queries are not supported by this connection")
 
 // SurrealConfig defines the configuration for the SurrealDB database.
 type SurrealConfig struct {
@@ -10,39 +25,119 @@ type SurrealConfig struct {
 	Password  string `json:"password,omitempty"`
 	Scope     string `json:"scope,omitempty"`
 	Username  string `json:"username,omitempty"`
+	// AuthMode is how the client authenticates, AuthModeRoot when empty.
+	AuthMode AuthMode `json:"authMode,omitempty"`
+	// Token is the JWT token of AuthModeToken.
+	Token string `json:"token,omitempty"`
+	// Access is the record access method of AuthModeRecord, signed in with
+	// AccessVariables, e.g. the email and password of the record user.
+	Access          string                 `json:"access,omitempty"`
+	AccessVariables map[string]interface{} `json:"accessVariables,omitempty"`
+	// OAuthPassThru makes queries run as the Grafana user, authenticated with
+	// the OAuth ID token forwarded by Grafana.
+	OAuthPassThru bool `json:"oauthPassThru,omitempty"`
+	// TLSSkipVerify disables the verification of the server certificate of
+	// `wss://` endpoints, and TLSServerName overrides the name it is verified
+	// against.
+	TLSSkipVerify bool   `json:"tlsSkipVerify,omitempty"`
+	TLSServerName string `json:"serverName,omitempty"`
+	// TLSCACert is the PEM certificate of the CA of the server certificate, and
+	// TLSClientCert and TLSClientKey are the PEM certificate and key presented
+	// to servers requiring mutual TLS.
+	TLSCACert     string `json:"tlsCACert,omitempty"`
+	TLSClientCert string `json:"tlsClientCert,omitempty"`
+	TLSClientKey  string `json:"tlsClientKey,omitempty"`
+	// MaxConcurrentQueries limits the number of queries run at the same time
+	// by the datasource, DefaultMaxConcurrentQueries when zero.
+	MaxConcurrentQueries int `json:"maxConcurrentQueries,omitempty"`
+	// MinConnections is the number of connections kept open by the pool.
+	MinConnections int `json:"minConnections,omitempty"`
+	// MaxConnections is the maximum number of connections opened by the pool,
+	// DefaultMaxConnections when zero.
+	MaxConnections int `json:"maxConnections,omitempty"`
+	// ConnIdleTimeout is the number of seconds after which idle connections
+	// are closed, DefaultConnIdleTimeout when zero.
+	ConnIdleTimeout int `json:"connIdleTimeout,omitempty"`
+	// ConnMaxLifetime is the number of seconds after which connections are
+	// replaced, unlimited when zero.
+	ConnMaxLifetime int `json:"connMaxLifetime,omitempty"`
+	// QueryTimeout is the number of seconds after which queries are aborted,
+	// unlimited when zero.
+	QueryTimeout int `json:"queryTimeout,omitempty"`
+	// MaxRows is the maximum number of rows returned per statement, unlimited
+	// when zero.
+	MaxRows int `json:"maxRows,omitempty"`
 }
 
+// DefaultMaxConcurrentQueries is the default limit of queries run at the same time by a datasource.
+const DefaultMaxConcurrentQueries = 10
+
 // SurrealDBClient defines the interface for the SurrealDB database.
 type SurrealDBClient interface {
 	Close()
+	Authenticate(token string) (interface{}, error)
 	Create(thing string, data interface{}) (interface{}, error)
 	Query(sql string, vars interface{}) (interface{}, error)
 	Signin(vars interface{}) (interface{}, error)
 	Use(namespace string, database string) (interface{}, error)
 }
 
+// LiveClient defines the interface for connections delivering live query notifications.
+type LiveClient interface {
+	Kill(id string) (interface{}, error)
+	Notifications(id string) (<-chan Notification, error)
+}
+
+// ContextQuerier is implemented by connections which can stop waiting for the
+// result of a query when its context is done.
+type ContextQuerier interface {
+	QueryContext(ctx context.Context, sql string, vars interface{}) (interface{}, error)
+}
+
+// acquirer is implemented by clients which can dedicate one of their
+// connections to a caller, such as pools.
+type acquirer interface {
+	Acquire(ctx context.Context) (SurrealDBClient, func(), error)
+}
+
+// liveConn is the connection receiving the notifications of a live query.
+type liveConn struct {
+	lc      LiveClient
+	release func()
+}
+
+const (
+	// reconnectAttempts is the number of attempts to reconnect after a connection loss.
+	reconnectAttempts = 5
+	// reconnectDelay is the delay before the second attempt to reconnect,
+	// doubled after each attempt up to maxReconnectDelay.
+	reconnectDelay    = 100 * time.Millisecond
+	maxReconnectDelay = 5 * time.Second
+)
+
 // Client defines the client for the SurrealDB database.
 type Client struct {
 	db SurrealDBClient
+
+	mu   sync.Mutex
+	live map[string]liveConn
+
+	// config is the configuration of the last successful Connect, used to
+	// sign in again when reconnecting.
+	config      *SurrealConfig
+	reconnectMu sync.Mutex
+	reconnects  atomic.Int64
 }
 
 // Use returns a new client for the SurrealDB database.
 func Use(db SurrealDBClient) *Client {
-	return &Client{db}
+	return &Client{db: db, live: map[string]liveConn{}}
 }
 
-// Connect connects to the SurrealDB database.
+// Connect connects to the SurrealDB database, authenticating according to the
+// auth mode of the config.
 func (c *Client) Connect(config *SurrealConfig) (bool, error) {
-	credentials := map[string]interface{}{
-		"user": config.Username,
-		"pass": config.Password,
-	}
-
-	if config.Scope != "" {
-		credentials["scope"] = config.Scope
-	}
-
-	if _, err := c.db.Signin(credentials); err != nil {
+	if err := authenticate(c.db, config); err != nil {
 		return false, err
 	}
 
@@ -50,16 +145,108 @@ func (c *Client) Connect(config *SurrealConfig) (bool, error) {
 		return false, err
 	}
 
+	c.mu.Lock()
+	c.config = config
+	c.mu.Unlock()
+
 	return true, nil
 }
 
-// QueryWithContext wraps the Query method to handle context for cancellation/timeout
+// QueryWithContext runs a query until ctx is done. Pools close the connection
+// of a query whose ctx is done first, which aborts it on the server, and the
+// deadline of ctx is added to SELECT statements as a TIMEOUT clause. Read-only
+// queries are run again when the connection is lost.
 func (c *Client) QueryWithContext(ctx context.Context, query string, args interface{}) (interface{}, error) {
-	rc := make(chan interface{})
-	ec := make(chan error)
+	reconnects := c.reconnects.Load()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		query = surrealql.WithTimeout(query, time.Until(deadline))
+	}
+
+	result, err := queryWithContext(ctx, c.db, query, args)
+	if !errors.Is(err, ErrClosed) {
+		return result, err
+	}
+
+	if rerr := c.reconnect(ctx, reconnects); rerr != nil {
+		return nil, fmt.Errorf("%w, reconnecting failed: %v", err, rerr)
+	}
+
+	if !surrealql.ReadOnly(query) {
+		return nil, err
+	}
+
+	return queryWithContext(ctx, c.db, query, args)
+}
+
+// Reconnects returns the number of times the client reconnected after losing
+// the connection.
+func (c *Client) Reconnects() int64 {
+	return c.reconnects.Load()
+}
+
+// reconnect signs in again with the configuration of the last successful
+// Connect, with exponential backoff between attempts. Pools replace their
+// closed connections with new ones, signed in again by Connect. Queries losing
+// the connection at the same time reconnect once: reconnects is the number of
+// reconnections when the query started, nothing is done if it has changed since.
+func (c *Client) reconnect(ctx context.Context, reconnects int64) error {
+	c.reconnectMu.Lock()
+	defer c.reconnectMu.Unlock()
+
+	if c.reconnects.Load() != reconnects {
+		return nil
+	}
+
+	c.mu.Lock()
+	config := c.config
+	c.mu.Unlock()
+
+	if config == nil {
+		return errors.New("not connected")
+	}
+
+	delay := reconnectDelay
+
+	for attempt := 1; ; attempt++ {
+		_, err := c.Connect(config)
+		if err == nil {
+			c.reconnects.Add(1)
+			return nil
+		}
+
+		if attempt == reconnectAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+// queryWithContext runs a query on db, returning early if ctx is done. The query
+// keeps running in the background when db cannot stop waiting for it, until it
+// returns.
+func queryWithContext(ctx context.Context, db SurrealDBClient, query string, args interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if cq, ok := db.(ContextQuerier); ok {
+		return cq.QueryContext(ctx, query, args)
+	}
+
+	// buffered, so the goroutine never blocks once the query returns
+	rc := make(chan interface{}, 1)
+	ec := make(chan error, 1)
 
 	go func() {
-		r, err := c.db.Query(query, args)
+		r, err := db.Query(query, args)
 		if err != nil {
 			ec <- err
 			return
@@ -76,3 +263,103 @@ func (c *Client) QueryWithContext(ctx context.Context, query string, args interf
 		return result, nil
 	}
 }
+
+// Close closes the connection to the database.
+func (c *Client) Close() {
+	c.db.Close()
+}
+
+// Live starts a live query, prefixing the query with `LIVE` if needed, and
+// returns its id along with the channel receiving its notifications. When the
+// client is a pool, a connection outside of its limit is dedicated to the live
+// query until it is killed.
+func (c *Client) Live(ctx context.Context, query string, args interface{}) (string, <-chan Notification, error) {
+	db, release := c.db, func() {}
+
+	if a, ok := c.db.(acquirer); ok {
+		var err error
+		if db, release, err = a.Acquire(ctx); err != nil {
+			return "", nil, err
+		}
+	}
+
+	lc, ok := db.(LiveClient)
+	if !ok {
+		release()
+		return "", nil, ErrLiveUnsupported
+	}
+
+	query = strings.TrimSpace(query)
+	if !strings.HasPrefix(strings.ToUpper(query), "LIVE ") {
+		query = "LIVE " + query
+	}
+
+	result, err := queryWithContext(ctx, db, query, args)
+	if err != nil {
+		release()
+		return "", nil, err
+	}
+
+	id, err := liveQueryID(result)
+	if err != nil {
+		release()
+		return "", nil, err
+	}
+
+	notifications, err := lc.Notifications(id)
+	if err != nil {
+		_, _ = lc.Kill(id)
+		release()
+		return "", nil, err
+	}
+
+	c.mu.Lock()
+	c.live[id] = liveConn{lc: lc, release: release}
+	c.mu.Unlock()
+
+	return id, notifications, nil
+}
+
+// Kill stops a live query.
+func (c *Client) Kill(id string) error {
+	c.mu.Lock()
+	conn, ok := c.live[id]
+	delete(c.live, id)
+	c.mu.Unlock()
+
+	if ok {
+		defer conn.release()
+
+		_, err := conn.lc.Kill(id)
+		return err
+	}
+
+	lc, ok := c.db.(LiveClient)
+	if !ok {
+		return ErrLiveUnsupported
+	}
+
+	_, err := lc.Kill(id)
+	return err
+}
+
+// liveQueryID returns the id of the live query from the result of a `LIVE SELECT` statement.
+func liveQueryID(result interface{}) (string, error) {
+	b, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+
+	var statements []surrealdb.RawQuery[json.RawMessage]
+
+	if err := json.Unmarshal(b, &statements); err != nil || len(statements) != 1 {
+		return "", fmt.Errorf("unexpected live query response: %s", b)
+	}
+
+	var id string
+	if err := json.Unmarshal(statements[0].Result, &id); err != nil || statements[0].Status != "OK" {
+		return "", fmt.Errorf("live query failed: %s", statements[0].Result)
+	}
+
+	return id, nil
+}
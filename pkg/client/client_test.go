@@ -1,8 +1,13 @@
 package client_test
 
 import (
+	"context"
 	"errors"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/grafana-labs/surrealdb-datasource/internal/mocks"
 	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
@@ -89,3 +94,263 @@ func TestConnect_UseError(t *testing.T) {
 		t.Error("expected error, got nil")
 	}
 }
+
+func TestConnect_AuthModes(t *testing.T) {
+	cases := []struct {
+		name   string
+		config client.SurrealConfig
+		signin map[string]interface{}
+		token  string
+	}{
+		{
+			name:   "root",
+			config: client.SurrealConfig{Username: "root", Password: "secret"},
+			signin: map[string]interface{}{"user": "root", "pass": "secret"},
+		},
+		{
+			name:   "root with scope",
+			config: client.SurrealConfig{Username: "root", Password: "secret", Scope: "viewer"},
+			signin: map[string]interface{}{"user": "root", "pass": "secret", "scope": "viewer"},
+		},
+		{
+			name:   "namespace",
+			config: client.SurrealConfig{AuthMode: client.AuthModeNamespace, Namespace: "ns", Database: "db", Username: "grafana", Password: "secret"},
+			signin: map[string]interface{}{"NS": "ns", "user": "grafana", "pass": "secret"},
+		},
+		{
+			name:   "database",
+			config: client.SurrealConfig{AuthMode: client.AuthModeDatabase, Namespace: "ns", Database: "db", Username: "grafana", Password: "secret"},
+			signin: map[string]interface{}{"NS": "ns", "DB": "db", "user": "grafana", "pass": "secret"},
+		},
+		{
+			name: "record",
+			config: client.SurrealConfig{
+				AuthMode:        client.AuthModeRecord,
+				Namespace:       "ns",
+				Database:        "db",
+				Access:          "account",
+				AccessVariables: map[string]interface{}{"email": "grafana@example.com", "pass": "secret"},
+			},
+			signin: map[string]interface{}{"NS": "ns", "DB": "db", "AC": "account", "email": "grafana@example.com", "pass": "secret"},
+		},
+		{
+			name:   "token",
+			config: client.SurrealConfig{AuthMode: client.AuthModeToken, Token: "eyJhbGciOiJIUzUxMiJ9"},
+			token:  "eyJhbGciOiJIUzUxMiJ9",
+		},
+		{
+			name:   "anonymous",
+			config: client.SurrealConfig{AuthMode: client.AuthModeAnonymous, Namespace: "ns", Database: "db"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var signin map[string]interface{}
+			var token string
+
+			mockDB := mocks.MockSurrealDBClient{
+				AuthenticateFunc: func(tok string) (interface{}, error) {
+					token = tok
+					return nil, nil
+				},
+				SigninFunc: func(vars interface{}) (interface{}, error) {
+					signin = vars.(map[string]interface{})
+					return nil, nil
+				},
+				UseFunc: func(namespace string, database string) (interface{}, error) {
+					return nil, nil
+				},
+			}
+
+			if _, err := client.Use(&mockDB).Connect(&tt.config); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(signin, tt.signin) {
+				t.Errorf("expected signin with %v, got %v", tt.signin, signin)
+			}
+			if token != tt.token {
+				t.Errorf("expected authentication with %q, got %q", tt.token, token)
+			}
+		})
+	}
+}
+
+func TestConnect_AuthModeErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		config client.SurrealConfig
+	}{
+		{name: "token without token", config: client.SurrealConfig{AuthMode: client.AuthModeToken}},
+		{name: "record without access", config: client.SurrealConfig{AuthMode: client.AuthModeRecord}},
+		{name: "unknown mode", config: client.SurrealConfig{AuthMode: "kerberos"}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := mocks.MockSurrealDBClient{}
+
+			if _, err := client.Use(&mockDB).Connect(&tt.config); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+// reconnectingDB is a database losing the connection on the first query.
+func reconnectingDB(signinErr error) (*mocks.MockSurrealDBClient, *int, *int) {
+	signins, queries := 0, 0
+
+	return &mocks.MockSurrealDBClient{
+		SigninFunc: func(vars interface{}) (interface{}, error) {
+			signins++
+			if signins > 1 && signinErr != nil {
+				return nil, signinErr
+			}
+			return nil, nil
+		},
+		UseFunc: func(namespace string, database string) (interface{}, error) {
+			return nil, nil
+		},
+		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+			queries++
+			if queries == 1 {
+				return nil, client.ErrClosed
+			}
+			return "result", nil
+		},
+	}, &signins, &queries
+}
+
+func TestQueryWithContext_ReconnectsAndReplaysReadOnlyQueries(t *testing.T) {
+	db, signins, queries := reconnectingDB(nil)
+	c := client.Use(db)
+
+	if _, err := c.Connect(&client.SurrealConfig{Namespace: "ns", Database: "db"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := c.QueryWithContext(context.Background(), "SELECT * FROM host", nil)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result != "result" {
+		t.Errorf("expected the query to run again, got %v", result)
+	}
+	if *signins != 2 || *queries != 2 {
+		t.Errorf("expected 2 signins and 2 queries, got %d and %d", *signins, *queries)
+	}
+	if reconnects := c.Reconnects(); reconnects != 1 {
+		t.Errorf("expected 1 reconnect, got %d", reconnects)
+	}
+}
+
+func TestQueryWithContext_DoesNotReplayWrites(t *testing.T) {
+	db, signins, queries := reconnectingDB(nil)
+	c := client.Use(db)
+
+	if _, err := c.Connect(&client.SurrealConfig{Namespace: "ns", Database: "db"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, err := c.QueryWithContext(context.Background(), "CREATE host SET name = 'a'", nil)
+
+	if !errors.Is(err, client.ErrClosed) {
+		t.Errorf("expected connection closed error, got %v", err)
+	}
+	if *signins != 2 || *queries != 1 {
+		t.Errorf("expected 2 signins and 1 query, got %d and %d", *signins, *queries)
+	}
+}
+
+func TestQueryWithContext_ReconnectFails(t *testing.T) {
+	db, _, queries := reconnectingDB(errors.New("connection refused"))
+	c := client.Use(db)
+
+	if _, err := c.Connect(&client.SurrealConfig{Namespace: "ns", Database: "db"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	_, err := c.QueryWithContext(ctx, "SELECT * FROM host", nil)
+
+	if !errors.Is(err, client.ErrClosed) {
+		t.Errorf("expected connection closed error, got %v", err)
+	}
+	if *queries != 1 {
+		t.Errorf("expected the query not to run again, got %d queries", *queries)
+	}
+	if reconnects := c.Reconnects(); reconnects != 0 {
+		t.Errorf("expected no reconnect, got %d", reconnects)
+	}
+}
+
+func TestQueryWithContext_CancelDoesNotLeak(t *testing.T) {
+	release := make(chan struct{})
+
+	mockDB := mocks.MockSurrealDBClient{
+		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+			<-release
+			return "result", nil
+		},
+	}
+
+	c := client.Use(&mockDB)
+
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err := c.QueryWithContext(ctx, "SELECT * FROM host", nil)
+		cancel()
+
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected deadline exceeded error, got %v", err)
+		}
+	}
+
+	// the queries return after their callers gave up on them
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d goroutines, got %d", before, runtime.NumGoroutine())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestQueryWithContext_Timeout(t *testing.T) {
+	var sql string
+
+	mockDB := mocks.MockSurrealDBClient{
+		QueryFunc: func(s string, vars interface{}) (interface{}, error) {
+			sql = s
+			return "result", nil
+		},
+	}
+
+	c := client.Use(&mockDB)
+
+	if _, err := c.QueryWithContext(context.Background(), "SELECT * FROM host", nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if sql != "SELECT * FROM host" {
+		t.Errorf("expected no timeout without deadline, got %q", sql)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if _, err := c.QueryWithContext(ctx, "SELECT * FROM host", nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.HasPrefix(sql, "SELECT * FROM host TIMEOUT ") || !strings.HasSuffix(sql, "ms") {
+		t.Errorf("expected a timeout clause, got %q", sql)
+	}
+}
@@ -0,0 +1,260 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// HTTP is a client of the SurrealDB REST endpoints, for deployments where
+// websockets are not available. Queries are sent to `/sql`, and the variables of
+// a query are bound with `LET` statements whose results are left out of the
+// response, so the results have the same shape as with the websocket RPC. Live
+// queries are not supported.
+type HTTP struct {
+	client  *http.Client
+	baseURL string
+
+	mu        sync.Mutex
+	token     string
+	namespace string
+	database  string
+}
+
+var (
+	_ SurrealDBClient = (*HTTP)(nil)
+	_ ContextQuerier  = (*HTTP)(nil)
+)
+
+// httpError is the body of the error responses of the REST endpoints.
+type httpError struct {
+	Details     string `json:"details"`
+	Information string `json:"information"`
+}
+
+// NewHTTP creates a client of the REST endpoints of the server at endpoint, e.g.
+// `https://localhost:8000`, using tlsConfig for `https://` endpoints, or the
+// defaults when nil.
+func NewHTTP(endpoint string, tlsConfig *tls.Config) *HTTP {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	baseURL := strings.TrimRight(endpoint, "/")
+	for _, suffix := range []string{"/rpc", "/sql"} {
+		baseURL = strings.TrimSuffix(baseURL, suffix)
+	}
+
+	return &HTTP{
+		client:  &http.Client{Transport: transport},
+		baseURL: baseURL,
+	}
+}
+
+// DialEndpoint opens a connection to the endpoint, over HTTP for `http://` and
+// `https://` endpoints, or over a websocket for `ws://` and `wss://` endpoints.
+func DialEndpoint(endpoint string, tlsConfig *tls.Config) (SurrealDBClient, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return NewHTTP(endpoint, tlsConfig), nil
+	case "ws", "wss":
+		return DialTLS(endpoint, tlsConfig)
+	default:
+		return nil, fmt.Errorf("unsupported endpoint scheme %q, expected ws, wss, http or https", u.Scheme)
+	}
+}
+
+// Close closes the idle connections of the client.
+func (h *HTTP) Close() {
+	h.client.CloseIdleConnections()
+}
+
+// Authenticate authenticates the requests with a JWT token.
+func (h *HTTP) Authenticate(token string) (interface{}, error) {
+	h.mu.Lock()
+	h.token = token
+	h.mu.Unlock()
+
+	return nil, nil
+}
+
+// Create creates a record in a table, or with the given id for `table:id`.
+func (h *HTTP) Create(thing string, data interface{}) (interface{}, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	path := "/key/" + url.PathEscape(thing)
+	if table, id, ok := strings.Cut(thing, ":"); ok {
+		path = "/key/" + url.PathEscape(table) + "/" + url.PathEscape(id)
+	}
+
+	return h.post(context.Background(), path, "application/json", body)
+}
+
+// Query runs a query.
+func (h *HTTP) Query(sql string, vars interface{}) (interface{}, error) {
+	return h.QueryContext(context.Background(), sql, vars)
+}
+
+// QueryContext runs a query, cancelling the request when ctx is done.
+func (h *HTTP) QueryContext(ctx context.Context, sql string, vars interface{}) (interface{}, error) {
+	lets, err := letStatements(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := h.post(ctx, "/sql", "text/plain", []byte(strings.Join(append(lets, sql), ";\n")))
+	if err != nil || len(lets) == 0 {
+		return result, err
+	}
+
+	var statements []json.RawMessage
+	if err := json.Unmarshal(result, &statements); err != nil || len(statements) < len(lets) {
+		return nil, fmt.Errorf("unexpected query response: %s", result)
+	}
+
+	b, err := json.Marshal(statements[len(lets):])
+	if err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(b), nil
+}
+
+// Signin signs in with vars, then authenticates the requests with the token
+// returned by the server.
+func (h *HTTP) Signin(vars interface{}) (interface{}, error) {
+	body, err := json.Marshal(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := h.post(context.Background(), "/signin", "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+
+	var signin struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(result, &signin); err != nil {
+		return nil, fmt.Errorf("unexpected signin response: %s", result)
+	}
+
+	h.mu.Lock()
+	h.token = signin.Token
+	h.mu.Unlock()
+
+	return signin.Token, nil
+}
+
+// Use selects the namespace and database of the requests.
+func (h *HTTP) Use(namespace string, database string) (interface{}, error) {
+	h.mu.Lock()
+	h.namespace, h.database = namespace, database
+	h.mu.Unlock()
+
+	return nil, nil
+}
+
+// post sends a request to a REST endpoint and returns the body of the response.
+// Requests whose ctx has no deadline time out after DefaultTimeout.
+func (h *HTTP) post(ctx context.Context, path string, contentType string, body []byte) (json.RawMessage, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultTimeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	token, namespace, database := h.token, h.namespace, h.database
+	h.mu.Unlock()
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", contentType)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if namespace != "" {
+		req.Header.Set("NS", namespace)
+	}
+	if database != "" {
+		req.Header.Set("DB", database)
+	}
+
+	res, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		msg := fmt.Sprintf("%s: %s", res.Status, bytes.TrimSpace(b))
+
+		var e httpError
+		if err := json.Unmarshal(b, &e); err == nil && e.Information != "" {
+			msg = e.Information
+		} else if err == nil && e.Details != "" {
+			msg = e.Details
+		}
+
+		return nil, &RPCError{Code: res.StatusCode, Message: msg}
+	}
+
+	return b, nil
+}
+
+// letStatements returns the `LET` statements binding the variables of a query,
+// as the `/sql` endpoint only binds variables from strings in the URL.
+func letStatements(vars interface{}) ([]string, error) {
+	if vars == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(b, &values); err != nil {
+		return nil, fmt.Errorf("query variables must be an object: %w", err)
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lets := make([]string, 0, len(names))
+	for _, name := range names {
+		lets = append(lets, fmt.Sprintf("LET $%s = %s", name, values[name]))
+	}
+
+	return lets, nil
+}
@@ -0,0 +1,125 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
+)
+
+// restServer is a stand-in for the SurrealDB REST endpoints, which records the
+// last query and the headers it was sent with.
+type restServer struct {
+	*httptest.Server
+
+	sql     string
+	headers http.Header
+}
+
+func newRESTServer(t *testing.T) *restServer {
+	s := &restServer{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /signin", func(w http.ResponseWriter, r *http.Request) {
+		var vars map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&vars); err != nil || vars["pass"] != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"code":403,"details":"Authentication failed","information":"There was a problem with authentication"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":200,"details":"Authentication succeeded","token":"signed-in"}`))
+	})
+	mux.HandleFunc("POST /sql", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		s.sql, s.headers = string(b), r.Header.Clone()
+
+		// one result per statement, the query itself returning a row
+		statements := strings.Split(s.sql, ";\n")
+		results := make([]map[string]interface{}, len(statements))
+		for i := range statements {
+			results[i] = map[string]interface{}{"time": "1ms", "status": "OK", "result": nil}
+		}
+		results[len(results)-1]["result"] = []interface{}{map[string]interface{}{"id": "host:web"}}
+
+		_ = json.NewEncoder(w).Encode(results)
+	})
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestHTTP_Query(t *testing.T) {
+	server := newRESTServer(t)
+
+	db, err := client.DialEndpoint(server.URL+"/sql", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer db.Close()
+
+	c := client.Use(db)
+	if _, err := c.Connect(&client.SurrealConfig{Namespace: "ns", Database: "db", Username: "root", Password: "secret"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := c.QueryWithContext(context.Background(), "SELECT * FROM host", map[string]interface{}{"from": "2024-01-01T00:00:00Z", "max": 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expectedSQL := "LET $from = \"2024-01-01T00:00:00Z\";\nLET $max = 10;\nSELECT * FROM host"
+	if server.sql != expectedSQL {
+		t.Errorf("expected query %q, got %q", expectedSQL, server.sql)
+	}
+	if ns, db, auth := server.headers.Get("NS"), server.headers.Get("DB"), server.headers.Get("Authorization"); ns != "ns" || db != "db" || auth != "Bearer signed-in" {
+		t.Errorf("expected namespace, database and token headers, got %q, %q and %q", ns, db, auth)
+	}
+
+	// the results of the LET statements are left out
+	b, _ := json.Marshal(result)
+	expected := `[{"result":[{"id":"host:web"}],"status":"OK","time":"1ms"}]`
+	if string(b) != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+}
+
+func TestHTTP_SigninError(t *testing.T) {
+	server := newRESTServer(t)
+
+	c := client.Use(client.NewHTTP(server.URL, nil))
+
+	_, err := c.Connect(&client.SurrealConfig{Username: "root", Password: "wrong"})
+	if err == nil || err.Error() != "There was a problem with authentication" {
+		t.Errorf("expected authentication error, got %v", err)
+	}
+}
+
+func TestHTTP_Token(t *testing.T) {
+	server := newRESTServer(t)
+
+	c := client.Use(client.NewHTTP(server.URL, nil))
+	if _, err := c.Connect(&client.SurrealConfig{AuthMode: client.AuthModeToken, Token: "jwt"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := c.QueryWithContext(context.Background(), "INFO FOR DB", nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if auth := server.headers.Get("Authorization"); auth != "Bearer jwt" {
+		t.Errorf("expected the token to be sent, got %q", auth)
+	}
+}
+
+func TestDialEndpoint_UnsupportedScheme(t *testing.T) {
+	if _, err := client.DialEndpoint("tcp://localhost:8000", nil); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
@@ -0,0 +1,438 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxConnections is the default maximum number of connections of a pool.
+	DefaultMaxConnections = 10
+	// DefaultConnIdleTimeout is the default time after which idle connections are closed.
+	DefaultConnIdleTimeout = 5 * time.Minute
+)
+
+// DialFunc opens a new connection to the database.
+type DialFunc func() (SurrealDBClient, error)
+
+// pooledConn is a connection of a pool.
+type pooledConn struct {
+	db       SurrealDBClient
+	created  time.Time
+	lastUsed time.Time
+}
+
+// Pool is a pool of connections to the database. Connections are opened on
+// demand, up to the maximum size of the pool, and are all signed in, or
+// authenticated, and set to use the namespace and database of the last calls to
+// Signin, Authenticate and Use. Idle
+// connections beyond the minimum size are closed after the idle timeout, and
+// connections older than the max lifetime are replaced.
+type Pool struct {
+	dial        DialFunc
+	min         int
+	idleTimeout time.Duration
+	maxLifetime time.Duration
+
+	// slots holds a token per open connection, idle or in use.
+	slots chan struct{}
+	idle  chan *pooledConn
+	done  chan struct{}
+
+	mu        sync.Mutex
+	signin    interface{}
+	token     string
+	namespace string
+	database  string
+	closed    bool
+}
+
+var (
+	_ SurrealDBClient = (*Pool)(nil)
+	_ ContextQuerier  = (*Pool)(nil)
+)
+
+// NewPool creates a pool of connections opened with dial, sized according to
+// the config. No connection is opened until the first call.
+func NewPool(config *SurrealConfig, dial DialFunc) *Pool {
+	size := config.MaxConnections
+	if size <= 0 {
+		size = DefaultMaxConnections
+	}
+
+	idleTimeout := time.Duration(config.ConnIdleTimeout) * time.Second
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultConnIdleTimeout
+	}
+
+	p := &Pool{
+		dial:        dial,
+		min:         min(config.MinConnections, size),
+		idleTimeout: idleTimeout,
+		maxLifetime: time.Duration(config.ConnMaxLifetime) * time.Second,
+		slots:       make(chan struct{}, size),
+		idle:        make(chan *pooledConn, size),
+		done:        make(chan struct{}),
+	}
+
+	go p.maintain()
+
+	return p
+}
+
+// Close closes the idle connections of the pool. Connections in use are closed
+// when they are released.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return
+	}
+
+	p.closed = true
+	close(p.done)
+	p.discardIdle()
+}
+
+// Authenticate authenticates a connection of the pool with a token, which is
+// then used to authenticate the connections opened later instead of signing in.
+// Other idle connections are closed.
+func (p *Pool) Authenticate(token string) (interface{}, error) {
+	return p.configure(func(db SurrealDBClient) (interface{}, error) {
+		return db.Authenticate(token)
+	}, func() {
+		p.signin, p.token = nil, token
+	})
+}
+
+// Create creates a record using a connection of the pool.
+func (p *Pool) Create(thing string, data interface{}) (interface{}, error) {
+	return p.do(func(db SurrealDBClient) (interface{}, error) {
+		return db.Create(thing, data)
+	})
+}
+
+// Query runs a query using a connection of the pool.
+func (p *Pool) Query(sql string, vars interface{}) (interface{}, error) {
+	return p.do(func(db SurrealDBClient) (interface{}, error) {
+		return db.Query(sql, vars)
+	})
+}
+
+// QueryContext runs a query using a connection of the pool, returning early if
+// ctx is done, including while waiting for a connection. The connection of a
+// query whose ctx is done before its result arrives is closed, so that the
+// server aborts the query rather than running it for nobody.
+func (p *Pool) QueryContext(ctx context.Context, sql string, vars interface{}) (interface{}, error) {
+	conn, err := p.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := queryWithContext(ctx, conn.db, sql, vars)
+	if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		p.discard(conn)
+		return nil, err
+	}
+	p.put(conn, err)
+
+	return result, err
+}
+
+// Signin signs in a connection of the pool with vars, which are then used to
+// sign in the connections opened later. Other idle connections are closed.
+func (p *Pool) Signin(vars interface{}) (interface{}, error) {
+	return p.configure(func(db SurrealDBClient) (interface{}, error) {
+		return db.Signin(vars)
+	}, func() {
+		p.signin, p.token = vars, ""
+	})
+}
+
+// Use sets the namespace and database of the connections of the pool, then
+// opens connections up to the minimum size of the pool.
+func (p *Pool) Use(namespace string, database string) (interface{}, error) {
+	result, err := p.configure(func(db SurrealDBClient) (interface{}, error) {
+		return db.Use(namespace, database)
+	}, func() {
+		p.namespace, p.database = namespace, database
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p.fill()
+
+	return result, nil
+}
+
+// Acquire opens a connection dedicated to the caller until release is called,
+// e.g. to receive the notifications of a live query on the connection which
+// started it. Dedicated connections do not count toward the maximum size of the
+// pool, so that long-running live queries never keep other queries waiting, and
+// they are closed when released.
+func (p *Pool) Acquire(ctx context.Context) (SurrealDBClient, func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+
+	if closed {
+		return nil, nil, ErrClosed
+	}
+
+	conn, err := p.open()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var once sync.Once
+
+	return conn.db, func() {
+		once.Do(conn.db.Close)
+	}, nil
+}
+
+// do calls fn with a connection of the pool.
+func (p *Pool) do(fn func(db SurrealDBClient) (interface{}, error)) (interface{}, error) {
+	conn, err := p.get(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := fn(conn.db)
+	p.put(conn, err)
+
+	return result, err
+}
+
+// configure calls fn with a connection of the pool and, if it succeeds, calls
+// update to apply the same settings to the connections opened later. The other
+// idle connections are closed since their settings are outdated.
+func (p *Pool) configure(fn func(db SurrealDBClient) (interface{}, error), update func()) (interface{}, error) {
+	conn, err := p.get(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := fn(conn.db)
+	if err != nil {
+		p.put(conn, err)
+		return nil, err
+	}
+
+	p.mu.Lock()
+	update()
+	p.discardIdle()
+	p.mu.Unlock()
+
+	p.put(conn, nil)
+
+	return result, nil
+}
+
+// get returns an idle connection or, if there is none, opens a new one, waiting
+// for a connection to be released if the pool is full.
+func (p *Pool) get(ctx context.Context) (*pooledConn, error) {
+	for {
+		var conn *pooledConn
+
+		select {
+		case <-p.done:
+			return nil, ErrClosed
+		case conn = <-p.idle:
+		default:
+			select {
+			case conn = <-p.idle:
+			case p.slots <- struct{}{}:
+				conn, err := p.open()
+				if err != nil {
+					<-p.slots
+					return nil, err
+				}
+				return conn, nil
+			case <-p.done:
+				return nil, ErrClosed
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		if p.expired(conn, time.Now()) {
+			p.discard(conn)
+			continue
+		}
+
+		return conn, nil
+	}
+}
+
+// put returns a connection to the pool, or closes it if it failed with a
+// connection error, it has outlived the max lifetime or the pool is closed.
+func (p *Pool) put(conn *pooledConn, err error) {
+	now := time.Now()
+	conn.lastUsed = now
+
+	if errors.Is(err, ErrClosed) {
+		// the server most likely went away, the idle connections are lost too
+		p.mu.Lock()
+		p.discardIdle()
+		p.mu.Unlock()
+
+		p.discard(conn)
+		return
+	}
+
+	if p.maxLifetime > 0 && now.Sub(conn.created) > p.maxLifetime {
+		p.discard(conn)
+		return
+	}
+
+	p.release(conn)
+}
+
+// release makes a connection idle, or closes it if the pool is closed.
+func (p *Pool) release(conn *pooledConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		p.discard(conn)
+		return
+	}
+
+	// never blocks: there are no more connections than slots
+	p.idle <- conn
+}
+
+// discard closes a connection taken out of the pool and frees its slot.
+func (p *Pool) discard(conn *pooledConn) {
+	conn.db.Close()
+	<-p.slots
+}
+
+// discardIdle closes the idle connections. p.mu must be held.
+func (p *Pool) discardIdle() {
+	for {
+		select {
+		case conn := <-p.idle:
+			p.discard(conn)
+		default:
+			return
+		}
+	}
+}
+
+// open opens a new connection, signed in or authenticated and set to use the
+// namespace and database of the pool.
+func (p *Pool) open() (*pooledConn, error) {
+	db, err := p.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	signin, token, namespace, database := p.signin, p.token, p.namespace, p.database
+	p.mu.Unlock()
+
+	if signin != nil {
+		if _, err := db.Signin(signin); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	if token != "" {
+		if _, err := db.Authenticate(token); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	if namespace != "" || database != "" {
+		if _, err := db.Use(namespace, database); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+
+	return &pooledConn{db: db, created: now, lastUsed: now}, nil
+}
+
+// expired reports whether an idle connection must be closed, either because it
+// has outlived the max lifetime or because it has been idle for too long while
+// the pool holds more than the minimum number of connections.
+func (p *Pool) expired(conn *pooledConn, now time.Time) bool {
+	if p.maxLifetime > 0 && now.Sub(conn.created) > p.maxLifetime {
+		return true
+	}
+
+	return now.Sub(conn.lastUsed) > p.idleTimeout && len(p.slots) > p.min
+}
+
+// fill opens idle connections up to the minimum size of the pool.
+func (p *Pool) fill() {
+	for len(p.slots) < p.min {
+		select {
+		case p.slots <- struct{}{}:
+		default:
+			return
+		}
+
+		conn, err := p.open()
+		if err != nil {
+			<-p.slots
+			return
+		}
+
+		p.release(conn)
+	}
+}
+
+// maintain periodically closes expired idle connections and reopens
+// connections up to the minimum size of the pool, until the pool is closed.
+func (p *Pool) maintain() {
+	interval := p.idleTimeout / 2
+	if p.maxLifetime > 0 && p.maxLifetime/2 < interval {
+		interval = p.maxLifetime / 2
+	}
+
+	ticker := time.NewTicker(max(interval, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		for i := len(p.idle); i > 0; i-- {
+			select {
+			case conn := <-p.idle:
+				if p.expired(conn, time.Now()) {
+					p.discard(conn)
+				} else {
+					p.release(conn)
+				}
+			default:
+			}
+		}
+
+		p.mu.Lock()
+		ready := p.signin != nil || p.token != "" || p.namespace != "" || p.database != ""
+		p.mu.Unlock()
+
+		if ready {
+			p.fill()
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana-labs/surrealdb-datasource/internal/mocks"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
+)
+
+// fakeServer counts the connections opened by a pool and what they are used for.
+type fakeServer struct {
+	dials           atomic.Int32
+	closes          atomic.Int32
+	authentications atomic.Int32
+	signins         atomic.Int32
+	uses            atomic.Int32
+
+	// query is called by the queries of all connections.
+	query func(conn int32, sql string) (interface{}, error)
+}
+
+func (s *fakeServer) dial() (client.SurrealDBClient, error) {
+	conn := s.dials.Add(1)
+
+	return &mocks.MockSurrealDBClient{
+		AuthenticateFunc: func(token string) (interface{}, error) {
+			s.authentications.Add(1)
+			return nil, nil
+		},
+		CloseFunc: func() {
+			s.closes.Add(1)
+		},
+		KillFunc: func(id string) (interface{}, error) {
+			return nil, nil
+		},
+		NotificationsFunc: func(id string) (<-chan client.Notification, error) {
+			return make(chan client.Notification), nil
+		},
+		SigninFunc: func(vars interface{}) (interface{}, error) {
+			s.signins.Add(1)
+			return "token", nil
+		},
+		UseFunc: func(namespace string, database string) (interface{}, error) {
+			s.uses.Add(1)
+			return nil, nil
+		},
+		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+			if s.query != nil {
+				return s.query(conn, sql)
+			}
+			return conn, nil
+		},
+	}, nil
+}
+
+func TestPool_Connect(t *testing.T) {
+	server := &fakeServer{}
+	config := client.SurrealConfig{Namespace: "ns", Database: "db", MinConnections: 3}
+
+	pool := client.NewPool(&config, server.dial)
+	defer pool.Close()
+
+	if _, err := client.Use(pool).Connect(&config); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if dials := server.dials.Load(); dials != 3 {
+		t.Errorf("expected 3 connections, got %d", dials)
+	}
+	// the first connection is signed in and used once, the others when opened
+	if signins, uses := server.signins.Load(), server.uses.Load(); signins != 3 || uses != 3 {
+		t.Errorf("expected every connection to be signed in and used once, got %d signins and %d uses", signins, uses)
+	}
+}
+
+func TestPool_Authenticate(t *testing.T) {
+	server := &fakeServer{}
+	config := client.SurrealConfig{AuthMode: client.AuthModeToken, Token: "token", Namespace: "ns", Database: "db", MinConnections: 2}
+
+	pool := client.NewPool(&config, server.dial)
+	defer pool.Close()
+
+	if _, err := client.Use(pool).Connect(&config); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if authentications, signins := server.authentications.Load(), server.signins.Load(); authentications != 2 || signins != 0 {
+		t.Errorf("expected every connection to be authenticated with the token, got %d authentications and %d signins", authentications, signins)
+	}
+}
+
+func TestPool_ReusesConnections(t *testing.T) {
+	server := &fakeServer{}
+	config := client.SurrealConfig{}
+
+	pool := client.NewPool(&config, server.dial)
+	defer pool.Close()
+
+	for i := 0; i < 5; i++ {
+		conn, err := pool.Query("SELECT * FROM host", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if conn != int32(1) {
+			t.Errorf("expected query to run on connection 1, got %v", conn)
+		}
+	}
+}
+
+func TestPool_MaxConnections(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+
+	server := &fakeServer{
+		query: func(conn int32, sql string) (interface{}, error) {
+			mu.Lock()
+			running++
+			maxRunning = max(maxRunning, running)
+			mu.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+
+			return conn, nil
+		},
+	}
+	config := client.SurrealConfig{MaxConnections: 2}
+
+	pool := client.NewPool(&config, server.dial)
+	defer pool.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := pool.Query("SELECT * FROM host", nil); err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxRunning != 2 {
+		t.Errorf("expected 2 queries running at the same time, got %d", maxRunning)
+	}
+	if dials := server.dials.Load(); dials != 2 {
+		t.Errorf("expected 2 connections, got %d", dials)
+	}
+}
+
+func TestPool_LiveQueries(t *testing.T) {
+	server := &fakeServer{
+		query: func(conn int32, sql string) (interface{}, error) {
+			if strings.HasPrefix(sql, "LIVE ") {
+				return []interface{}{map[string]interface{}{"status": "OK", "result": fmt.Sprintf("live-%d", conn)}}, nil
+			}
+			return conn, nil
+		},
+	}
+	config := client.SurrealConfig{MaxConnections: 2}
+
+	pool := client.NewPool(&config, server.dial)
+	defer pool.Close()
+
+	c := client.Use(pool)
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		id, _, err := c.Live(t.Context(), "SELECT * FROM sensor", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		ids = append(ids, id)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+
+	// the live queries leave the connections of the pool to other queries
+	for i := 0; i < 2; i++ {
+		if _, err := c.QueryWithContext(ctx, "SELECT * FROM host", nil); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	for _, id := range ids {
+		if err := c.Kill(id); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if closes := server.closes.Load(); closes != 3 {
+		t.Errorf("expected the connections of the live queries to be closed, got %d closes", closes)
+	}
+
+	canceled, cancelNow := context.WithCancel(t.Context())
+	cancelNow()
+	if _, _, err := c.Live(canceled, "SELECT * FROM sensor", nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the live query to be canceled, got %v", err)
+	}
+}
+
+func TestPool_ClosesCanceledQueries(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	started := make(chan struct{}, 1)
+	server := &fakeServer{
+		query: func(conn int32, sql string) (interface{}, error) {
+			if conn == 1 {
+				started <- struct{}{}
+				<-release
+			}
+			return conn, nil
+		},
+	}
+	config := client.SurrealConfig{MaxConnections: 1}
+
+	pool := client.NewPool(&config, server.dial)
+	defer pool.Close()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	if _, err := pool.QueryContext(ctx, "SELECT * FROM host", nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the query to be canceled, got %v", err)
+	}
+	if closes := server.closes.Load(); closes != 1 {
+		t.Errorf("expected the connection of the canceled query to be closed, got %d closes", closes)
+	}
+
+	// the slot of the closed connection is free for a new one
+	conn, err := pool.QueryContext(t.Context(), "SELECT * FROM host", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if conn != int32(2) {
+		t.Errorf("expected the query to run on a new connection, got %v", conn)
+	}
+}
+
+func TestPool_MaxLifetime(t *testing.T) {
+	server := &fakeServer{}
+	config := client.SurrealConfig{ConnMaxLifetime: 1}
+
+	pool := client.NewPool(&config, server.dial)
+	defer pool.Close()
+
+	if conn, _ := pool.Query("SELECT * FROM host", nil); conn != int32(1) {
+		t.Fatalf("expected query to run on connection 1, got %v", conn)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	if conn, _ := pool.Query("SELECT * FROM host", nil); conn != int32(2) {
+		t.Errorf("expected query to run on a new connection, got %v", conn)
+	}
+	if closes := server.closes.Load(); closes != 1 {
+		t.Errorf("expected the expired connection to be closed, got %d closes", closes)
+	}
+}
+
+func TestPool_DiscardsClosedConnections(t *testing.T) {
+	server := &fakeServer{
+		query: func(conn int32, sql string) (interface{}, error) {
+			if conn == 1 {
+				return nil, client.ErrClosed
+			}
+			return conn, nil
+		},
+	}
+	config := client.SurrealConfig{}
+
+	pool := client.NewPool(&config, server.dial)
+	defer pool.Close()
+
+	if _, err := pool.Query("SELECT * FROM host", nil); !errors.Is(err, client.ErrClosed) {
+		t.Fatalf("expected connection closed error, got %v", err)
+	}
+
+	if conn, _ := pool.Query("SELECT * FROM host", nil); conn != int32(2) {
+		t.Errorf("expected query to run on a new connection, got %v", conn)
+	}
+}
+
+func TestPool_Close(t *testing.T) {
+	server := &fakeServer{}
+	config := client.SurrealConfig{MinConnections: 2}
+
+	pool := client.NewPool(&config, server.dial)
+
+	if _, err := client.Use(pool).Connect(&config); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	pool.Close()
+
+	if closes := server.closes.Load(); closes != 2 {
+		t.Errorf("expected 2 connections to be closed, got %d", closes)
+	}
+	if _, err := pool.Query("SELECT * FROM host", nil); !errors.Is(err, client.ErrClosed) {
+		t.Errorf("expected connection closed error, got %v", err)
+	}
+}
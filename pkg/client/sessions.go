@@ -0,0 +1,194 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSessionTTL is the time after which the sessions of users are closed,
+// unless their token expires earlier.
+const DefaultSessionTTL = 5 * time.Minute
+
+// session is a client authenticated with the token of a user.
+type session struct {
+	client  *Client
+	token   string
+	expires time.Time
+}
+
+// pendingSession is the connection of the session of a user in progress, which other
+// queries of the user wait for.
+type pendingSession struct {
+	token string
+	done  chan struct{}
+
+	// sess and err are set when done is closed.
+	sess *session
+	err  error
+}
+
+// Sessions keeps a client per user, authenticated with the token of the user so
+// queries run with the permissions of the user. Sessions are closed when the
+// token of the user expires or changes, or after DefaultSessionTTL.
+type Sessions struct {
+	config *SurrealConfig
+	dial   DialFunc
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	dials    map[string]*pendingSession
+	closed   bool
+}
+
+// NewSessions creates the sessions of users connecting with dial, according to
+// the config apart from the authentication.
+func NewSessions(config *SurrealConfig, dial DialFunc) *Sessions {
+	return &Sessions{config: config, dial: dial, sessions: map[string]*session{}, dials: map[string]*pendingSession{}}
+}
+
+// Get returns the client of a user, authenticated with token, connecting a new
+// one if the user has no session or the token has changed. The lock is only
+// held to look up and store sessions, so connecting a user does not hold up
+// the others, and concurrent queries of a user share the same connection.
+func (s *Sessions) Get(user string, token string) (*Client, error) {
+	for {
+		s.mu.Lock()
+		stale := s.prune(time.Now())
+
+		if sess, ok := s.sessions[user]; ok && sess.token == token {
+			s.mu.Unlock()
+			closeClients(stale)
+			return sess.client, nil
+		}
+
+		if d, ok := s.dials[user]; ok {
+			s.mu.Unlock()
+			closeClients(stale)
+
+			<-d.done
+			if d.token == token {
+				if d.err != nil {
+					return nil, d.err
+				}
+				return d.sess.client, nil
+			}
+			// connected with another token, look again
+			continue
+		}
+
+		if sess, ok := s.sessions[user]; ok {
+			stale = append(stale, sess.client)
+			delete(s.sessions, user)
+		}
+
+		d := &pendingSession{token: token, done: make(chan struct{})}
+		s.dials[user] = d
+		s.mu.Unlock()
+		closeClients(stale)
+
+		d.sess, d.err = s.connect(token)
+
+		s.mu.Lock()
+		delete(s.dials, user)
+		if d.err == nil && s.closed {
+			d.sess.client.Close()
+			d.sess, d.err = nil, ErrClosed
+		}
+		if d.err == nil {
+			s.sessions[user] = d.sess
+		}
+		s.mu.Unlock()
+		close(d.done)
+
+		if d.err != nil {
+			return nil, d.err
+		}
+		return d.sess.client, nil
+	}
+}
+
+// connect connects a new session authenticated with token.
+func (s *Sessions) connect(token string) (*session, error) {
+	config := *s.config
+	config.AuthMode = AuthModeToken
+	config.Token = token
+	config.MinConnections = 0
+
+	pool := NewPool(&config, s.dial)
+	c := Use(pool)
+
+	if _, err := c.Connect(&config); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	expires := time.Now().Add(DefaultSessionTTL)
+	if exp, ok := tokenExpiry(token); ok && exp.Before(expires) {
+		expires = exp
+	}
+
+	return &session{client: c, token: token, expires: expires}, nil
+}
+
+// Close closes the sessions of all users, and those being connected once they
+// are.
+func (s *Sessions) Close() {
+	s.mu.Lock()
+	s.closed = true
+	clients := make([]*Client, 0, len(s.sessions))
+	for user, sess := range s.sessions {
+		clients = append(clients, sess.client)
+		delete(s.sessions, user)
+	}
+	s.mu.Unlock()
+
+	closeClients(clients)
+}
+
+// prune removes the expired sessions and returns their clients, which are
+// closed once s.mu is released. s.mu must be held.
+func (s *Sessions) prune(now time.Time) []*Client {
+	var expired []*Client
+
+	for user, sess := range s.sessions {
+		if !now.Before(sess.expires) {
+			expired = append(expired, sess.client)
+			delete(s.sessions, user)
+		}
+	}
+
+	return expired
+}
+
+// closeClients closes clients.
+func closeClients(clients []*Client) {
+	for _, c := range clients {
+		c.Close()
+	}
+}
+
+// tokenExpiry returns the expiry of a JWT token from its `exp` claim. The token
+// is not verified, which is left to the database.
+func tokenExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(claims.Exp, 0), true
+}
@@ -0,0 +1,147 @@
+package client_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
+)
+
+// idToken returns an unsigned JWT token expiring at exp.
+func idToken(exp time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":"user","exp":%d}`, exp.Unix())))
+
+	return "eyJhbGciOiJub25lIn0." + payload + ".signature"
+}
+
+func TestSessions_ReusesSessions(t *testing.T) {
+	server := &fakeServer{}
+	sessions := client.NewSessions(&client.SurrealConfig{Namespace: "ns", Database: "db"}, server.dial)
+	defer sessions.Close()
+
+	token := idToken(time.Now().Add(time.Hour))
+
+	first, err := sessions.Get("alice", token)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	second, err := sessions.Get("alice", token)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if first != second {
+		t.Error("expected the session of the user to be reused")
+	}
+	if authentications, signins := server.authentications.Load(), server.signins.Load(); authentications != 1 || signins != 0 {
+		t.Errorf("expected the session to be authenticated with the token once, got %d authentications and %d signins", authentications, signins)
+	}
+}
+
+func TestSessions_SeparatesUsers(t *testing.T) {
+	server := &fakeServer{}
+	sessions := client.NewSessions(&client.SurrealConfig{}, server.dial)
+	defer sessions.Close()
+
+	token := idToken(time.Now().Add(time.Hour))
+
+	alice, _ := sessions.Get("alice", token)
+	bob, _ := sessions.Get("bob", token)
+
+	if alice == bob {
+		t.Error("expected each user to have their own session")
+	}
+}
+
+func TestSessions_NewToken(t *testing.T) {
+	server := &fakeServer{}
+	sessions := client.NewSessions(&client.SurrealConfig{}, server.dial)
+	defer sessions.Close()
+
+	first, _ := sessions.Get("alice", idToken(time.Now().Add(time.Hour)))
+	second, _ := sessions.Get("alice", idToken(time.Now().Add(2*time.Hour)))
+
+	if first == second {
+		t.Error("expected a new session for the new token")
+	}
+	if closes := server.closes.Load(); closes != 1 {
+		t.Errorf("expected the previous session to be closed, got %d closes", closes)
+	}
+}
+
+func TestSessions_ExpiredToken(t *testing.T) {
+	server := &fakeServer{}
+	sessions := client.NewSessions(&client.SurrealConfig{}, server.dial)
+	defer sessions.Close()
+
+	token := idToken(time.Now().Add(-time.Minute))
+
+	first, _ := sessions.Get("alice", token)
+	second, _ := sessions.Get("alice", token)
+
+	if first == second {
+		t.Error("expected the expired session not to be reused")
+	}
+}
+
+func TestSessions_ConcurrentDials(t *testing.T) {
+	server := &fakeServer{}
+	blocked := make(chan struct{})
+	release := make(chan struct{})
+
+	// the first connection, of alice, waits until released
+	var once sync.Once
+	dial := func() (client.SurrealDBClient, error) {
+		first := false
+		once.Do(func() { first = true })
+		if first {
+			close(blocked)
+			<-release
+		}
+		return server.dial()
+	}
+
+	sessions := client.NewSessions(&client.SurrealConfig{}, dial)
+	defer sessions.Close()
+
+	token := idToken(time.Now().Add(time.Hour))
+
+	results := make(chan *client.Client, 2)
+	for range 2 {
+		go func() {
+			c, err := sessions.Get("alice", token)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			results <- c
+		}()
+	}
+
+	<-blocked
+
+	done := make(chan struct{})
+	go func() {
+		if _, err := sessions.Get("bob", token); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected bob to connect while alice is connecting")
+	}
+
+	close(release)
+
+	if first, second := <-results, <-results; first != second {
+		t.Error("expected the concurrent queries of alice to share their session")
+	}
+	if dials := server.dials.Load(); dials != 2 {
+		t.Errorf("expected one connection per user, got %d", dials)
+	}
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// TLSConfig returns the TLS configuration of `wss://` endpoints, or nil when the
+// config has no TLS settings and the defaults apply.
+func TLSConfig(config *SurrealConfig) (*tls.Config, error) {
+	if !config.TLSSkipVerify && config.TLSServerName == "" && config.TLSCACert == "" && config.TLSClientCert == "" && config.TLSClientKey == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         config.TLSServerName,
+		InsecureSkipVerify: config.TLSSkipVerify,
+	}
+
+	if config.TLSCACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(config.TLSCACert)) {
+			return nil, errors.New("invalid CA certificate: no PEM certificate found")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.TLSClientCert != "" || config.TLSClientKey != "" {
+		cert, err := tls.X509KeyPair([]byte(config.TLSClientCert), []byte(config.TLSClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
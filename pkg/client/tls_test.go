@@ -0,0 +1,156 @@
+package client_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
+)
+
+// newClientCert returns a self-signed client certificate and its key, as PEM.
+func newClientCert(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "grafana"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+// newMTLSServer starts a stand-in for the SurrealDB RPC endpoint requiring
+// clients to present clientCert, and returns its websocket URL along with the
+// PEM certificate of the server.
+func newMTLSServer(t *testing.T, clientCert string) (string, string) {
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var req struct {
+			ID string `json:"id"`
+		}
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		_ = conn.WriteJSON(map[string]interface{}{"id": req.ID, "result": "pong"})
+	}))
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM([]byte(clientCert))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	serverCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	return "wss" + strings.TrimPrefix(server.URL, "https"), string(serverCert)
+}
+
+func TestTLSConfig(t *testing.T) {
+	if tlsConfig, err := client.TLSConfig(&client.SurrealConfig{}); tlsConfig != nil || err != nil {
+		t.Errorf("expected no TLS configuration without TLS settings, got %v, %v", tlsConfig, err)
+	}
+
+	tlsConfig, err := client.TLSConfig(&client.SurrealConfig{TLSSkipVerify: true, TLSServerName: "surrealdb.internal"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !tlsConfig.InsecureSkipVerify || tlsConfig.ServerName != "surrealdb.internal" {
+		t.Errorf("expected skip verify and server name to be set, got %v and %q", tlsConfig.InsecureSkipVerify, tlsConfig.ServerName)
+	}
+
+	if _, err := client.TLSConfig(&client.SurrealConfig{TLSCACert: "not a certificate"}); err == nil {
+		t.Error("expected error for an invalid CA certificate, got nil")
+	}
+
+	if _, err := client.TLSConfig(&client.SurrealConfig{TLSClientCert: "not a certificate"}); err == nil {
+		t.Error("expected error for an invalid client certificate, got nil")
+	}
+}
+
+func TestDialTLS_MutualTLS(t *testing.T) {
+	clientCert, clientKey := newClientCert(t)
+	endpoint, serverCert := newMTLSServer(t, clientCert)
+
+	cases := []struct {
+		name   string
+		config client.SurrealConfig
+		ok     bool
+	}{
+		{
+			name:   "client certificate",
+			config: client.SurrealConfig{TLSCACert: serverCert, TLSClientCert: clientCert, TLSClientKey: clientKey, TLSServerName: "example.com"},
+			ok:     true,
+		},
+		{
+			name:   "without client certificate",
+			config: client.SurrealConfig{TLSCACert: serverCert, TLSServerName: "example.com"},
+		},
+		{
+			name:   "unknown CA",
+			config: client.SurrealConfig{TLSClientCert: clientCert, TLSClientKey: clientKey},
+		},
+		{
+			name:   "skip verify",
+			config: client.SurrealConfig{TLSSkipVerify: true, TLSClientCert: clientCert, TLSClientKey: clientKey},
+			ok:     true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig, err := client.TLSConfig(&tt.config)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			ws, err := client.DialTLS(endpoint, tlsConfig)
+			if err == nil {
+				// the server checks the client certificate once the handshake completes
+				_, err = ws.Query("INFO FOR DB", nil)
+				ws.Close()
+			}
+
+			if ok := err == nil; ok != tt.ok {
+				t.Errorf("expected success %v, got error %v", tt.ok, err)
+			}
+		})
+	}
+}
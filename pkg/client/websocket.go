@@ -0,0 +1,412 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// DefaultTimeout is the time to wait for a response to a request whose
+	// context has no deadline, which otherwise bounds the wait.
+	DefaultTimeout = 30 * time.Second
+
+	// notificationBuffer is the number of live query notifications buffered per live query.
+	notificationBuffer = 64
+)
+
+var (
+	// ErrClosed is returned when sending a request on a closed connection.
+	ErrClosed = errors.New("connection closed")
+	// ErrTimeout is returned when no response was received in time.
+	ErrTimeout = errors.New("timeout waiting for response")
+)
+
+// RPCError is an error returned by the SurrealDB RPC endpoint.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// Notification is a live query notification sent by SurrealDB when a record
+// matching a live query is created, updated or deleted.
+type Notification struct {
+	ID     string          `json:"id"`
+	Action string          `json:"action"`
+	Result json.RawMessage `json:"result"`
+}
+
+// rpcRequest is a request sent to the SurrealDB RPC endpoint.
+type rpcRequest struct {
+	ID     string        `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params,omitempty"`
+}
+
+// rpcResponse is a message received from the SurrealDB RPC endpoint, either a
+// response to a request or, when it has no id, a live query notification.
+type rpcResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+}
+
+// WebSocket is a connection to the SurrealDB RPC endpoint. Unlike the
+// surrealdb.go client it delivers live query notifications, which is what
+// the streaming support relies on.
+type WebSocket struct {
+	conn    *websocket.Conn
+	timeout time.Duration
+
+	nextID    atomic.Uint64
+	writeLock sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]chan rpcResponse
+	live    map[string]chan Notification
+	// liveRequests are the ids of the pending `LIVE SELECT` requests, whose
+	// responses register the live queries.
+	liveRequests map[string]bool
+	// early are the notifications of unregistered live queries received while
+	// live queries are started, which may belong to them.
+	early []Notification
+	// killed are the ids of the killed live queries, whose notifications are
+	// dropped.
+	killed map[string]bool
+	closed chan struct{}
+	err    error
+}
+
+var _ SurrealDBClient = (*WebSocket)(nil)
+var _ ContextQuerier = (*WebSocket)(nil)
+var _ LiveClient = (*WebSocket)(nil)
+
+// Dial opens a connection to the SurrealDB RPC endpoint, e.g. `ws://localhost:8000/rpc`.
+func Dial(endpoint string) (*WebSocket, error) {
+	return DialTLS(endpoint, nil)
+}
+
+// DialTLS opens a connection to the SurrealDB RPC endpoint, using tlsConfig for
+// `wss://` endpoints, or the defaults when nil.
+func DialTLS(endpoint string, tlsConfig *tls.Config) (*WebSocket, error) {
+	dialer := *websocket.DefaultDialer
+	dialer.TLSClientConfig = tlsConfig
+
+	conn, _, err := dialer.Dial(endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := &WebSocket{
+		conn:         conn,
+		timeout:      DefaultTimeout,
+		pending:      make(map[string]chan rpcResponse),
+		live:         make(map[string]chan Notification),
+		liveRequests: make(map[string]bool),
+		killed:       make(map[string]bool),
+		closed:       make(chan struct{}),
+	}
+
+	go ws.read()
+
+	return ws, nil
+}
+
+// Close closes the connection.
+func (ws *WebSocket) Close() {
+	ws.writeLock.Lock()
+	_ = ws.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	ws.writeLock.Unlock()
+
+	ws.shutdown(ErrClosed)
+}
+
+// Create creates a record in the database.
+func (ws *WebSocket) Create(thing string, data interface{}) (interface{}, error) {
+	return ws.send("create", thing, data)
+}
+
+// Query runs a SurrealQL query with the given parameters.
+func (ws *WebSocket) Query(sql string, vars interface{}) (interface{}, error) {
+	return ws.send("query", sql, vars)
+}
+
+// QueryContext runs a SurrealQL query with the given parameters, returning
+// early if ctx is done. The response is then discarded when it arrives.
+func (ws *WebSocket) QueryContext(ctx context.Context, sql string, vars interface{}) (interface{}, error) {
+	return ws.sendContext(ctx, "query", sql, vars)
+}
+
+// Authenticate authenticates the connection with a JWT token.
+func (ws *WebSocket) Authenticate(token string) (interface{}, error) {
+	return ws.send("authenticate", token)
+}
+
+// Signin signs in to the database.
+func (ws *WebSocket) Signin(vars interface{}) (interface{}, error) {
+	return ws.send("signin", vars)
+}
+
+// Use selects the namespace and database to use.
+func (ws *WebSocket) Use(namespace string, database string) (interface{}, error) {
+	return ws.send("use", namespace, database)
+}
+
+// Kill stops a live query and closes its notification channel.
+func (ws *WebSocket) Kill(id string) (interface{}, error) {
+	res, err := ws.send("kill", id)
+
+	ws.mu.Lock()
+	ws.killed[id] = true
+	if ch, ok := ws.live[id]; ok {
+		delete(ws.live, id)
+		close(ch)
+	}
+	ws.mu.Unlock()
+
+	return res, err
+}
+
+// Notifications returns the channel receiving the notifications of a live query.
+func (ws *WebSocket) Notifications(id string) (<-chan Notification, error) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.err != nil {
+		return nil, ws.err
+	}
+	if ws.killed[id] {
+		return nil, fmt.Errorf("live query %s was killed", id)
+	}
+
+	return ws.liveChannel(id), nil
+}
+
+// liveChannel returns the notification channel of a live query, creating it
+// with the notifications received before it was registered. It must be called
+// with mu held.
+func (ws *WebSocket) liveChannel(id string) chan Notification {
+	ch, ok := ws.live[id]
+	if ok {
+		return ch
+	}
+
+	ch = make(chan Notification, notificationBuffer)
+	ws.live[id] = ch
+
+	early := ws.early[:0]
+	for _, n := range ws.early {
+		if n.ID == id {
+			ch <- n
+			continue
+		}
+		early = append(early, n)
+	}
+	ws.early = early
+
+	return ch
+}
+
+// registerLive registers the live queries started by the response of a
+// `LIVE SELECT` request. It must be called with mu held.
+func (ws *WebSocket) registerLive(result json.RawMessage) {
+	var statements []struct {
+		Status string          `json:"status"`
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(result, &statements); err != nil {
+		return
+	}
+
+	for _, statement := range statements {
+		var id string
+		if statement.Status == "OK" && json.Unmarshal(statement.Result, &id) == nil && id != "" {
+			ws.liveChannel(id)
+		}
+	}
+}
+
+// isLiveQuery reports whether a request starts a live query.
+func isLiveQuery(method string, params []interface{}) bool {
+	if method != "query" || len(params) == 0 {
+		return false
+	}
+
+	sql, ok := params[0].(string)
+	return ok && strings.HasPrefix(strings.ToUpper(strings.TrimSpace(sql)), "LIVE ")
+}
+
+// send sends a request and waits for its response.
+func (ws *WebSocket) send(method string, params ...interface{}) (interface{}, error) {
+	return ws.sendContext(context.Background(), method, params...)
+}
+
+// sendContext sends a request and waits for its response until ctx is done.
+func (ws *WebSocket) sendContext(ctx context.Context, method string, params ...interface{}) (interface{}, error) {
+	id := strconv.FormatUint(ws.nextID.Add(1), 10)
+	ch := make(chan rpcResponse, 1)
+
+	ws.mu.Lock()
+	if ws.err != nil {
+		ws.mu.Unlock()
+		return nil, ws.err
+	}
+	ws.pending[id] = ch
+	live := isLiveQuery(method, params)
+	if live {
+		ws.liveRequests[id] = true
+	}
+	ws.mu.Unlock()
+
+	defer func() {
+		ws.mu.Lock()
+		delete(ws.pending, id)
+		if live {
+			delete(ws.liveRequests, id)
+			if len(ws.liveRequests) == 0 {
+				// the early notifications belong to no live query
+				ws.early = nil
+			}
+		}
+		ws.mu.Unlock()
+	}()
+
+	req, err := json.Marshal(rpcRequest{ID: id, Method: method, Params: params})
+	if err != nil {
+		return nil, err
+	}
+
+	ws.writeLock.Lock()
+	err = ws.conn.WriteMessage(websocket.TextMessage, req)
+	ws.writeLock.Unlock()
+
+	if err != nil {
+		return nil, fmt.Errorf("sending request failed for method '%s': %w", method, err)
+	}
+
+	// the deadline of ctx, e.g. the query timeout, replaces the default timeout
+	var timeout <-chan time.Time
+	if _, ok := ctx.Deadline(); !ok {
+		timer := time.NewTimer(ws.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case res := <-ch:
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		return res.Result, nil
+	case <-ws.closed:
+		return nil, ws.closeErr()
+	case <-timeout:
+		return nil, ErrTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// read dispatches the messages received on the connection until it fails.
+func (ws *WebSocket) read() {
+	for {
+		_, msg, err := ws.conn.ReadMessage()
+		if err != nil {
+			ws.shutdown(fmt.Errorf("%w: %v", ErrClosed, err))
+			return
+		}
+
+		var res rpcResponse
+		if err := json.Unmarshal(msg, &res); err != nil {
+			continue
+		}
+
+		var id string
+		if err := json.Unmarshal(res.ID, &id); err != nil || id == "" {
+			ws.notify(res.Result)
+			continue
+		}
+
+		ws.mu.Lock()
+		ch, ok := ws.pending[id]
+		if ws.liveRequests[id] && res.Error == nil {
+			// registered before any later notification is read
+			ws.registerLive(res.Result)
+		}
+		ws.mu.Unlock()
+
+		if ok {
+			ch <- res
+		}
+	}
+}
+
+// notify delivers a live query notification to its channel. Notifications
+// are dropped when nobody keeps up with them, and when their live query is
+// killed or unknown, unless live queries are being started.
+func (ws *WebSocket) notify(result json.RawMessage) {
+	var n Notification
+	if err := json.Unmarshal(result, &n); err != nil || n.ID == "" {
+		return
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.killed[n.ID] {
+		return
+	}
+
+	ch, ok := ws.live[n.ID]
+	if !ok {
+		if len(ws.liveRequests) > 0 && len(ws.early) < notificationBuffer {
+			ws.early = append(ws.early, n)
+		}
+		return
+	}
+
+	select {
+	case ch <- n:
+	default:
+	}
+}
+
+// shutdown marks the connection as closed with err and releases all waiters.
+func (ws *WebSocket) shutdown(err error) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.err != nil {
+		return
+	}
+
+	ws.err = err
+	close(ws.closed)
+	_ = ws.conn.Close()
+
+	for id, ch := range ws.live {
+		delete(ws.live, id)
+		close(ch)
+	}
+}
+
+// closeErr returns the error the connection was closed with.
+func (ws *WebSocket) closeErr() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	return ws.err
+}
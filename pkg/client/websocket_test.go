@@ -0,0 +1,190 @@
+package client_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
+)
+
+// newRPCServer starts a stand-in for the SurrealDB RPC endpoint which answers
+// each request with handle, and returns its websocket URL.
+func newRPCServer(t *testing.T, handle func(conn *websocket.Conn, method string, params []json.RawMessage) interface{}) string {
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			var req struct {
+				ID     string            `json:"id"`
+				Method string            `json:"method"`
+				Params []json.RawMessage `json:"params"`
+			}
+
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+
+			result := handle(conn, req.Method, req.Params)
+
+			if err := conn.WriteJSON(map[string]interface{}{"id": req.ID, "result": result}); err != nil {
+				return
+			}
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestWebSocket_Query(t *testing.T) {
+	endpoint := newRPCServer(t, func(_ *websocket.Conn, method string, params []json.RawMessage) interface{} {
+		return []interface{}{
+			map[string]interface{}{"status": "OK", "result": []interface{}{method, string(params[0])}, "time": "1ms"},
+		}
+	})
+
+	ws, err := client.Dial(endpoint)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer ws.Close()
+
+	result, err := ws.Query("SELECT * FROM person", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	b, _ := json.Marshal(result)
+	expected := `[{"result":["query","\"SELECT * FROM person\""],"status":"OK","time":"1ms"}]`
+
+	if string(b) != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+}
+
+func TestWebSocket_Notifications(t *testing.T) {
+	endpoint := newRPCServer(t, func(conn *websocket.Conn, method string, _ []json.RawMessage) interface{} {
+		if method == "query" {
+			// notifications have no id and may arrive before anyone listens
+			_ = conn.WriteJSON(map[string]interface{}{
+				"result": map[string]interface{}{
+					"id":     "live-id",
+					"action": "CREATE",
+					"result": map[string]interface{}{"id": "sensor:1"},
+				},
+			})
+
+			return []interface{}{
+				map[string]interface{}{"status": "OK", "result": "live-id", "time": "1ms"},
+			}
+		}
+		return nil
+	})
+
+	ws, err := client.Dial(endpoint)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer ws.Close()
+
+	id, notifications, err := client.Use(ws).Live(t.Context(), "SELECT * FROM sensor", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if id != "live-id" {
+		t.Errorf("expected live query id 'live-id', got %s", id)
+	}
+
+	select {
+	case n := <-notifications:
+		if n.Action != "CREATE" || string(n.Result) != `{"id":"sensor:1"}` {
+			t.Errorf("unexpected notification: %+v", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a notification")
+	}
+
+	if _, err := ws.Kill(id); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, ok := <-notifications; ok {
+		t.Error("expected notifications to be closed after kill")
+	}
+}
+
+func TestWebSocket_UnknownNotifications(t *testing.T) {
+	endpoint := newRPCServer(t, func(conn *websocket.Conn, method string, _ []json.RawMessage) interface{} {
+		if method == "query" {
+			for _, id := range []string{"stray-id", "killed-id"} {
+				_ = conn.WriteJSON(map[string]interface{}{
+					"result": map[string]interface{}{"id": id, "action": "CREATE", "result": nil},
+				})
+			}
+		}
+		return []interface{}{}
+	})
+
+	ws, err := client.Dial(endpoint)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer ws.Close()
+
+	if _, err := ws.Kill("killed-id"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// notifications arriving while no live query is started are dropped
+	if _, err := ws.Query("SELECT * FROM sensor", nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	notifications, err := ws.Notifications("stray-id")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	select {
+	case n := <-notifications:
+		t.Errorf("expected the notification of an unknown live query to be dropped, got %+v", n)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if _, err := ws.Notifications("killed-id"); err == nil {
+		t.Error("expected an error for a killed live query, got nil")
+	}
+}
+
+func TestWebSocket_ConnectionLost(t *testing.T) {
+	endpoint := newRPCServer(t, func(conn *websocket.Conn, _ string, _ []json.RawMessage) interface{} {
+		_ = conn.Close()
+		return nil
+	})
+
+	ws, err := client.Dial(endpoint)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer ws.Close()
+
+	if _, err := ws.Query("SELECT * FROM person", nil); err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	if _, err := ws.Query("SELECT * FROM person", nil); err == nil {
+		t.Error("expected error on closed connection, got nil")
+	}
+}
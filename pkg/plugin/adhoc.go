@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/grafana-labs/surrealdb-datasource/pkg/surrealql"
+)
+
+// AdhocFilter is an ad-hoc filter of a dashboard, e.g. `host = web`.
+type AdhocFilter struct {
+	Key      string `json:"key"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+	// Type is the type of the field of the key, from its definition, which
+	// tells how the value is formatted.
+	Type ValueType `json:"type,omitempty"`
+}
+
+// comparisonOperators are the SurrealQL operators of the ad-hoc filter operators
+// which compare a field with a value.
+var comparisonOperators = map[string]string{
+	"=":  "=",
+	"!=": "!=",
+	"<":  "<",
+	">":  ">",
+}
+
+// applyAdhocFilters adds the condition of the ad-hoc filters to the WHERE clause
+// of the SELECT statements of a query.
+func applyAdhocFilters(sql string, filters []AdhocFilter) (string, error) {
+	if len(filters) == 0 {
+		return sql, nil
+	}
+
+	cond, err := adhocCondition(filters)
+	if err != nil {
+		return "", err
+	}
+
+	statements := surrealql.Split(sql)
+	for i, stmt := range statements {
+		statements[i], _ = surrealql.AddCondition(stmt, cond)
+	}
+
+	return strings.Join(statements, ";\n"), nil
+}
+
+// adhocCondition builds the condition matching all the ad-hoc filters. Keys are
+// escaped and values are formatted as SurrealQL literals of the type of the key.
+//
+//	host = web, cpu > 80 (number) => host = 'web' AND cpu > 80
+//	host =~ ^web => string::matches(host, '^web')
+func adhocCondition(filters []AdhocFilter) (string, error) {
+	conditions := make([]string, 0, len(filters))
+
+	for _, filter := range filters {
+		if filter.Key == "" {
+			return "", errors.New("ad-hoc filter without key")
+		}
+
+		field := formatFieldPath(filter.Key)
+
+		switch filter.Operator {
+		case "=~":
+			conditions = append(conditions, fmt.Sprintf("string::matches(%s, %s)", field, quoteString(filter.Value)))
+		case "!~":
+			conditions = append(conditions, fmt.Sprintf("!string::matches(%s, %s)", field, quoteString(filter.Value)))
+		default:
+			op, ok := comparisonOperators[filter.Operator]
+			if !ok {
+				return "", fmt.Errorf("unsupported ad-hoc filter operator %q", filter.Operator)
+			}
+			conditions = append(conditions, fmt.Sprintf("%s %s %s", field, op, formatValue(filter.Value, filter.Type)))
+		}
+	}
+
+	return strings.Join(conditions, " AND "), nil
+}
+
+// formatFieldPath escapes the parts of a field path, e.g. `host.name`.
+func formatFieldPath(path string) string {
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+		parts[i] = escapeIdent(part)
+	}
+
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,302 @@
+package plugin_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana-labs/surrealdb-datasource/internal/mocks"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/plugin"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestAdhocFilters(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		filters  []plugin.AdhocFilter
+		expected string
+		err      bool
+	}{
+		{
+			name:     "equal",
+			input:    "SELECT * FROM metrics",
+			filters:  []plugin.AdhocFilter{{Key: "host", Operator: "=", Value: "web"}},
+			expected: "SELECT * FROM metrics WHERE host = 'web'",
+		},
+		{
+			name:  "comparisons",
+			input: "SELECT * FROM metrics WHERE $__timeFilter(time) ORDER BY time",
+			filters: []plugin.AdhocFilter{
+				{Key: "cpu", Operator: ">", Value: "80", Type: plugin.ValueTypeNumber},
+				{Key: "mem", Operator: "<", Value: "0.5", Type: plugin.ValueTypeNumber},
+				{Key: "host", Operator: "!=", Value: "host:a", Type: plugin.ValueTypeRecord},
+			},
+			expected: "SELECT * FROM metrics WHERE (time >= d'2023-11-27T22:30:23Z' AND time <= d'2023-11-28T22:30:23.5Z') AND cpu > 80 AND mem < 0.5 AND host != host:a ORDER BY time",
+		},
+		{
+			name:  "untyped values",
+			input: "SELECT * FROM metrics",
+			filters: []plugin.AdhocFilter{
+				{Key: "version", Operator: "=", Value: "1.10"},
+				{Key: "region", Operator: "=", Value: "region:eu"},
+			},
+			expected: "SELECT * FROM metrics WHERE version = '1.10' AND region = 'region:eu'",
+		},
+		{
+			name:     "invalid typed value",
+			input:    "SELECT * FROM metrics",
+			filters:  []plugin.AdhocFilter{{Key: "cpu", Operator: ">", Value: "1 OR true", Type: plugin.ValueTypeNumber}},
+			expected: "SELECT * FROM metrics WHERE cpu > '1 OR true'",
+		},
+		{
+			name:  "regular expressions",
+			input: "SELECT * FROM metrics",
+			filters: []plugin.AdhocFilter{
+				{Key: "host", Operator: "=~", Value: `^web-\d+`},
+				{Key: "region", Operator: "!~", Value: "^eu"},
+			},
+			expected: `SELECT * FROM metrics WHERE string::matches(host, '^web-\\d+') AND !string::matches(region, '^eu')`,
+		},
+		{
+			name:     "escaped keys and values",
+			input:    "SELECT * FROM metrics",
+			filters:  []plugin.AdhocFilter{{Key: "tags.my-tag", Operator: "=", Value: "it's' OR true OR '"}},
+			expected: "SELECT * FROM metrics WHERE tags.`my-tag` = 'it\\'s\\' OR true OR \\''",
+		},
+		{
+			name:     "several statements",
+			input:    "LET $threshold = 80; SELECT * FROM metrics WHERE cpu > $threshold",
+			filters:  []plugin.AdhocFilter{{Key: "host", Operator: "=", Value: "web"}},
+			expected: "LET $threshold = 80;\nSELECT * FROM metrics WHERE (cpu > $threshold) AND host = 'web'",
+		},
+		{
+			name:     "values looking like macros",
+			input:    "SELECT * FROM metrics",
+			filters:  []plugin.AdhocFilter{{Key: "host", Operator: "=", Value: "$__timeFilter(a)"}},
+			expected: "SELECT * FROM metrics WHERE host = '$__timeFilter(a)'",
+		},
+		{
+			name:    "unsupported operator",
+			input:   "SELECT * FROM metrics",
+			filters: []plugin.AdhocFilter{{Key: "host", Operator: "=|", Value: "web"}},
+			err:     true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var sql string
+
+			mock := mocks.MockSurrealDBClient{
+				QueryFunc: func(s string, vars interface{}) (interface{}, error) {
+					sql = s
+					return []interface{}{
+						map[string]interface{}{"status": "OK", "result": []interface{}{}, "time": "1ms"},
+					}, nil
+				},
+			}
+
+			ds := plugin.NewDatasourceInstance(client.Use(&mock), &config)
+
+			model, err := json.Marshal(plugin.SurrealQuery{RawSQL: tt.input, AdhocFilters: tt.filters})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			response := ds.CreateDataResponse(context.TODO(), backend.DataQuery{
+				RefID: "A",
+				JSON:  model,
+				TimeRange: backend.TimeRange{
+					From: time.Date(2023, 11, 27, 22, 30, 23, 0, time.UTC),
+					To:   time.Date(2023, 11, 28, 22, 30, 23, 500000000, time.UTC),
+				},
+			})
+
+			if tt.err {
+				if response.Error == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+
+			if response.Error != nil {
+				t.Fatalf("unexpected error: %s", response.Error)
+			}
+			if sql != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, sql)
+			}
+		})
+	}
+}
+
+// tagMock answers the INFO and SELECT statements used by the tag routes.
+var tagMock = mocks.MockSurrealDBClient{
+	QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+		var results []interface{}
+
+		for _, stmt := range strings.Split(sql, "; ") {
+			var result interface{}
+
+			switch stmt {
+			case "INFO FOR DB":
+				result = map[string]interface{}{
+					"tables": map[string]interface{}{"host": "", "metrics": ""},
+				}
+			case "INFO FOR TABLE host":
+				result = map[string]interface{}{
+					"fields": map[string]interface{}{
+						"name":   "DEFINE FIELD name ON host TYPE string",
+						"region": "DEFINE FIELD region ON host TYPE record<region>",
+					},
+				}
+			case "INFO FOR TABLE metrics":
+				result = map[string]interface{}{
+					"fields": map[string]interface{}{
+						"region":  "DEFINE FIELD region ON metrics TYPE string",
+						"cpu":     "DEFINE FIELD cpu ON metrics TYPE option<float>",
+						"tags":    "DEFINE FIELD tags ON metrics TYPE array",
+						"tags[*]": "DEFINE FIELD tags[*] ON metrics TYPE string",
+					},
+				}
+			case "SELECT region AS tag FROM host GROUP BY tag LIMIT 1000":
+				result = []interface{}{map[string]interface{}{"tag": "eu"}, map[string]interface{}{"tag": "us"}, map[string]interface{}{"tag": nil}}
+			case "SELECT region AS tag FROM metrics GROUP BY tag LIMIT 1000":
+				result = []interface{}{map[string]interface{}{"tag": "us"}, map[string]interface{}{"tag": "ap"}, map[string]interface{}{"tag": 1}}
+			}
+
+			results = append(results, map[string]interface{}{"status": "OK", "result": result, "time": "1ms"})
+		}
+
+		return results, nil
+	},
+}
+
+func TestCallResource_TagKeys(t *testing.T) {
+	ds := plugin.NewDatasourceInstance(client.Use(&tagMock), &config)
+
+	var response *backend.CallResourceResponse
+
+	err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+		Method: http.MethodGet,
+		Path:   "tag-keys",
+		URL:    "tag-keys",
+	}, backend.CallResourceResponseSenderFunc(func(res *backend.CallResourceResponse) error {
+		response = res
+		return nil
+	}))
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if response.Status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", response.Status, response.Body)
+	}
+
+	var keys []plugin.TagKey
+	if err := json.Unmarshal(response.Body, &keys); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// region is a record on host and a string on metrics
+	expected := []plugin.TagKey{
+		{Text: "cpu", Type: plugin.ValueTypeNumber},
+		{Text: "name"},
+		{Text: "region"},
+		{Text: "tags"},
+	}
+	if !slices.Equal(keys, expected) {
+		t.Errorf("expected %v, got %v", expected, keys)
+	}
+}
+
+func TestCallResource_Tags(t *testing.T) {
+	cases := []struct {
+		name     string
+		path     string
+		expected []string
+	}{
+		{
+			name:     "values",
+			path:     "tag-values?key=region",
+			expected: []string{"1", "ap", "eu", "us"},
+		},
+		{
+			name:     "values of unknown key",
+			path:     "tag-values?key=unknown",
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := plugin.NewDatasourceInstance(client.Use(&tagMock), &config)
+
+			var response *backend.CallResourceResponse
+
+			err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+				Method: http.MethodGet,
+				Path:   strings.Split(tt.path, "?")[0],
+				URL:    tt.path,
+			}, backend.CallResourceResponseSenderFunc(func(res *backend.CallResourceResponse) error {
+				response = res
+				return nil
+			}))
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if response.Status != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", response.Status, response.Body)
+			}
+
+			var values []string
+			if err := json.Unmarshal(response.Body, &values); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if strings.Join(values, ",") != strings.Join(tt.expected, ",") || values == nil {
+				t.Errorf("expected %v, got %v", tt.expected, values)
+			}
+		})
+	}
+}
+
+func TestCallResource_TagValuesLimit(t *testing.T) {
+	var sql string
+
+	limitMock := mocks.MockSurrealDBClient{
+		QueryFunc: func(s string, vars interface{}) (interface{}, error) {
+			if strings.HasPrefix(s, "SELECT") {
+				sql = s
+			}
+			return tagMock.QueryFunc(s, vars)
+		},
+	}
+
+	limited := config
+	limited.MaxRows = 10
+
+	ds := plugin.NewDatasourceInstance(client.Use(&limitMock), &limited)
+
+	err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+		Method: http.MethodGet,
+		Path:   "tag-values",
+		URL:    "tag-values?key=region",
+	}, backend.CallResourceResponseSenderFunc(func(res *backend.CallResourceResponse) error {
+		return nil
+	}))
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := "SELECT region AS tag FROM host GROUP BY tag LIMIT 10; SELECT region AS tag FROM metrics GROUP BY tag LIMIT 10"
+	if sql != expected {
+		t.Errorf("expected %q, got %q", expected, sql)
+	}
+}
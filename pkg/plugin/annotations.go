@@ -0,0 +1,150 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const (
+	// timeEndColumnName is the name of the column holding the end of region annotations.
+	timeEndColumnName = "timeEnd"
+	// titleColumnName is the name of the column holding the title of annotations.
+	titleColumnName = "title"
+	// tagsColumnName is the name of the column holding the tags of annotations.
+	tagsColumnName = "tags"
+)
+
+var errNoAnnotationTime = errors.New("annotation queries require a datetime column, e.g. `SELECT time, title, text, tags FROM ...`")
+
+// toAnnotationFrame converts a frame into the `time`, `timeEnd`, `title`, `text`
+// and `tags` fields expected by annotations. The time comes from the `time`
+// column, or the first datetime column, and the tags from an array column, or
+// a string column of comma separated tags. The tags are JSON arrays, so Grafana
+// does not split them again. Rows without a time are dropped.
+func toAnnotationFrame(frame *data.Frame) (*data.Frame, error) {
+	timeField := annotationTimeField(frame)
+	if timeField == nil {
+		return nil, errNoAnnotationTime
+	}
+
+	timeEndField, _ := frame.FieldByName(timeEndColumnName)
+	if timeEndField != nil && !timeEndField.Type().Time() {
+		timeEndField = nil
+	}
+	titleField, _ := frame.FieldByName(titleColumnName)
+	textField, _ := frame.FieldByName(textColumnName)
+	tagsField, _ := frame.FieldByName(tagsColumnName)
+
+	annotations := data.NewFrame("annotation",
+		data.NewField(timeColumnName, nil, []time.Time{}),
+		data.NewField(timeEndColumnName, nil, []*time.Time{}),
+		data.NewField(titleColumnName, nil, []string{}),
+		data.NewField(textColumnName, nil, []string{}),
+		data.NewField(tagsColumnName, nil, []json.RawMessage{}),
+	)
+
+	for i := 0; i < timeField.Len(); i++ {
+		t, ok := timeField.ConcreteAt(i)
+		if !ok {
+			continue
+		}
+
+		var timeEnd *time.Time
+		if timeEndField != nil {
+			if v, ok := timeEndField.ConcreteAt(i); ok {
+				end := v.(time.Time)
+				timeEnd = &end
+			}
+		}
+
+		tags, _ := json.Marshal(fieldTags(tagsField, i))
+
+		annotations.AppendRow(
+			t.(time.Time),
+			timeEnd,
+			optionalFieldString(titleField, i),
+			optionalFieldString(textField, i),
+			json.RawMessage(tags),
+		)
+	}
+
+	return annotations, nil
+}
+
+// annotationTimeField returns the field holding the time of annotations: the
+// `time` field, or the first datetime field other than `timeEnd`.
+func annotationTimeField(frame *data.Frame) *data.Field {
+	var first *data.Field
+
+	for _, field := range frame.Fields {
+		if !field.Type().Time() {
+			continue
+		}
+		if field.Name == timeColumnName {
+			return field
+		}
+		if first == nil && field.Name != timeEndColumnName {
+			first = field
+		}
+	}
+
+	return first
+}
+
+// optionalFieldString returns the value of a field at idx as a string, or an
+// empty string if the field is missing.
+func optionalFieldString(field *data.Field, idx int) string {
+	if field == nil {
+		return ""
+	}
+
+	return fieldString(field, idx)
+}
+
+// fieldTags returns the tags held by a field at idx: the elements of an array,
+// kept as they are, or the comma separated tags of a string. Columns mixing
+// arrays and strings hold the JSON text of the arrays, which are decoded as well.
+//
+//	["deploy", "api, web"] => "deploy", "api, web"
+//	"deploy, api" => "deploy", "api"
+func fieldTags(field *data.Field, idx int) []string {
+	tags := []string{}
+	if field == nil {
+		return tags
+	}
+
+	v, ok := field.ConcreteAt(idx)
+	if !ok {
+		return tags
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal([]byte(fieldString(field, idx)), &elements); err == nil {
+		for _, element := range elements {
+			var s string
+			if err := json.Unmarshal(element, &s); err != nil {
+				s = string(element)
+			}
+			tags = append(tags, s)
+		}
+		return tags
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		// other values, e.g. numbers, are a single tag
+		return append(tags, fieldString(field, idx))
+	}
+
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
@@ -0,0 +1,139 @@
+package plugin_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana-labs/surrealdb-datasource/internal/mocks"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/plugin"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestCreateDataResponse_Annotation(t *testing.T) {
+	var sql string
+
+	annotationMock := mocks.MockSurrealDBClient{
+		QueryFunc: func(s string, vars interface{}) (interface{}, error) {
+			sql = s
+			return []interface{}{
+				map[string]interface{}{"status": "OK", "time": "1ms", "result": []interface{}{
+					map[string]interface{}{
+						"time":    "2023-11-28T10:00:00Z",
+						"timeEnd": "2023-11-28T10:05:00Z",
+						"title":   "Deploy v1.2.0",
+						"text":    "Deployed by CI",
+						"tags":    []interface{}{"deploy", "api, web"},
+						"id":      "event:a",
+					},
+					map[string]interface{}{
+						"time":    "2023-11-28T12:00:00Z",
+						"timeEnd": nil,
+						"title":   "Incident",
+						"text":    nil,
+						"tags":    "incident, api",
+						"id":      "event:b",
+					},
+					map[string]interface{}{
+						"time":    nil,
+						"timeEnd": nil,
+						"title":   "Without time",
+						"text":    nil,
+						"tags":    nil,
+						"id":      "event:c",
+					},
+				}},
+			}, nil
+		},
+	}
+
+	ds := plugin.NewDatasourceInstance(client.Use(&annotationMock), &config)
+
+	response := ds.CreateDataResponse(context.TODO(), backend.DataQuery{
+		RefID:     "Anno",
+		QueryType: plugin.QueryTypeAnnotation,
+		JSON:      []byte(`{"rawSql": "SELECT * FROM event WHERE $__timeFilter(time)"}`),
+		TimeRange: backend.TimeRange{
+			From: time.Date(2023, 11, 27, 22, 30, 23, 0, time.UTC),
+			To:   time.Date(2023, 11, 28, 22, 30, 23, 500000000, time.UTC),
+		},
+	})
+
+	if response.Error != nil {
+		t.Fatalf("unexpected error: %s", response.Error)
+	}
+
+	expectedSQL := "SELECT * FROM event WHERE time >= d'2023-11-27T22:30:23Z' AND time <= d'2023-11-28T22:30:23.5Z'"
+	if sql != expectedSQL {
+		t.Errorf("expected %q, got %q", expectedSQL, sql)
+	}
+
+	if len(response.Frames) != 1 {
+		t.Fatalf("expected 1 frame, got %d", len(response.Frames))
+	}
+
+	frame := response.Frames[0]
+
+	names := []string{"time", "timeEnd", "title", "text", "tags"}
+	if len(frame.Fields) != len(names) {
+		t.Fatalf("expected %d fields, got %d", len(names), len(frame.Fields))
+	}
+	for i, name := range names {
+		if frame.Fields[i].Name != name {
+			t.Errorf("expected field %d to be %s, got %s", i, name, frame.Fields[i].Name)
+		}
+	}
+
+	if frame.Rows() != 2 {
+		t.Fatalf("expected 2 rows, got %d", frame.Rows())
+	}
+
+	if at := frame.Fields[0].At(0).(time.Time); !at.Equal(time.Date(2023, 11, 28, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected time %s", at)
+	}
+	if end := frame.Fields[1].At(0).(*time.Time); end == nil || !end.Equal(time.Date(2023, 11, 28, 10, 5, 0, 0, time.UTC)) {
+		t.Errorf("unexpected time end %v", end)
+	}
+	if end := frame.Fields[1].At(1).(*time.Time); end != nil {
+		t.Errorf("expected no time end, got %s", end)
+	}
+	if title := frame.Fields[2].At(0); title != "Deploy v1.2.0" {
+		t.Errorf("unexpected title %q", title)
+	}
+	if text := frame.Fields[3].At(1); text != "" {
+		t.Errorf("expected empty text, got %q", text)
+	}
+
+	expectedTags := []string{`["deploy","api, web"]`, `["incident","api"]`}
+	for i, expected := range expectedTags {
+		if tags := frame.Fields[4].At(i).(json.RawMessage); string(tags) != expected {
+			t.Errorf("expected tags %s in row %d, got %s", expected, i, tags)
+		}
+	}
+}
+
+func TestCreateDataResponse_AnnotationWithoutTime(t *testing.T) {
+	annotationMock := mocks.MockSurrealDBClient{
+		QueryFunc: func(s string, vars interface{}) (interface{}, error) {
+			return []interface{}{
+				map[string]interface{}{"status": "OK", "time": "1ms", "result": []interface{}{
+					map[string]interface{}{"title": "Deploy"},
+				}},
+			}, nil
+		},
+	}
+
+	ds := plugin.NewDatasourceInstance(client.Use(&annotationMock), &config)
+
+	response := ds.CreateDataResponse(context.TODO(), backend.DataQuery{
+		RefID:     "Anno",
+		QueryType: plugin.QueryTypeAnnotation,
+		JSON:      []byte(`{"rawSql": "SELECT title FROM event"}`),
+	})
+
+	if response.Error == nil {
+		t.Error("expected error, got nil")
+	}
+}
@@ -3,7 +3,9 @@ package plugin
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
@@ -11,27 +13,94 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
 	"github.com/grafana/grafana-plugin-sdk-go/experimental/errorsource"
 	"github.com/grafana/grafana-plugin-sdk-go/experimental/slo"
-	"github.com/surrealdb/surrealdb.go"
 )
 
 var (
 	_ backend.QueryDataHandler      = (*SurrealDatasource)(nil)
 	_ backend.CheckHealthHandler    = (*SurrealDatasource)(nil)
+	_ backend.CallResourceHandler   = (*SurrealDatasource)(nil)
+	_ backend.StreamHandler         = (*SurrealDatasource)(nil)
 	_ instancemgmt.InstanceDisposer = (*SurrealDatasource)(nil)
+
+	_ backend.StreamHandler         = (*Instance)(nil)
+	_ instancemgmt.InstanceDisposer = (*Instance)(nil)
 )
 
+// errNoIDToken is returned when the identity of the user is forwarded but
+// Grafana did not forward their OAuth ID token.
+var errNoIDToken = errors.New("no OAuth ID token to forward, the user must sign in to Grafana with OAuth")
+
+// statusCanceled is the status of the responses of queries canceled by Grafana,
+// e.g. when the dashboard is refreshed, which the SDK has no constant for: the
+// `499 Client Closed Request` status Grafana gives to canceled requests.
+const statusCanceled backend.Status = 499
+
 // SurrealDatasource defines how to connect to the datasource and describes the query model.
 type SurrealDatasource struct {
 	client *client.Client
 	config *client.SurrealConfig
+
+	resourceHandler backend.CallResourceHandler
+
+	// sessions holds the clients of the Grafana users when their identity is
+	// forwarded, nil otherwise.
+	sessions *client.Sessions
+
+	// slots limits the number of queries run at the same time.
+	slots chan struct{}
+
+	streamsMu sync.Mutex
+	streams   map[string]*liveQuery
 }
 
 // NewDatasourceInstance creates a new SurrealDatasource instance.
 func NewDatasourceInstance(client *client.Client, config *client.SurrealConfig) *SurrealDatasource {
-	return &SurrealDatasource{
-		client: client,
-		config: config,
+	ds := &SurrealDatasource{
+		client:  client,
+		config:  config,
+		slots:   make(chan struct{}, maxConcurrentQueries(config)),
+		streams: map[string]*liveQuery{},
 	}
+	ds.resourceHandler = ds.newResourceHandler()
+
+	return ds
+}
+
+// maxConcurrentQueries returns the number of queries a datasource runs at the same time.
+func maxConcurrentQueries(config *client.SurrealConfig) int {
+	if config.MaxConcurrentQueries <= 0 {
+		return client.DefaultMaxConcurrentQueries
+	}
+
+	return config.MaxConcurrentQueries
+}
+
+// Instance is the datasource instance managed by the SDK. The metrics wrapper
+// only forwards queries, health checks and resource calls, so streaming and
+// disposal are forwarded to the datasource here.
+type Instance struct {
+	*slo.MetricsWrapper
+	datasource *SurrealDatasource
+}
+
+// SubscribeStream forwards stream subscriptions to the datasource.
+func (i *Instance) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	return i.datasource.SubscribeStream(ctx, req)
+}
+
+// PublishStream forwards stream publications to the datasource.
+func (i *Instance) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return i.datasource.PublishStream(ctx, req)
+}
+
+// RunStream forwards running streams to the datasource.
+func (i *Instance) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	return i.datasource.RunStream(ctx, req, sender)
+}
+
+// Dispose disposes the datasource.
+func (i *Instance) Dispose() {
+	i.datasource.Dispose()
 }
 
 // NewDatasource creates a new datasource instance.
@@ -44,35 +113,109 @@ func NewDatasource(ctx context.Context, dsiConfig backend.DataSourceInstanceSett
 	}
 
 	config.Password = dsiConfig.DecryptedSecureJSONData["password"]
+	config.Token = dsiConfig.DecryptedSecureJSONData["token"]
 
-	db, err := surrealdb.New(config.Endpoint)
+	// the signin variables of record access usually hold credentials
+	if vars := dsiConfig.DecryptedSecureJSONData["accessVariables"]; vars != "" {
+		if err := json.Unmarshal([]byte(vars), &config.AccessVariables); err != nil {
+			return nil, fmt.Errorf("unable to get access variables from secure JSON data: %w", err)
+		}
+	}
+
+	config.TLSCACert = dsiConfig.DecryptedSecureJSONData["tlsCACert"]
+	config.TLSClientCert = dsiConfig.DecryptedSecureJSONData["tlsClientCert"]
+	config.TLSClientKey = dsiConfig.DecryptedSecureJSONData["tlsClientKey"]
+
+	tlsConfig, err := client.TLSConfig(&config)
 	if err != nil {
-		return nil, errorsource.DownstreamError(err, false)
+		return nil, fmt.Errorf("unable to get TLS settings: %w", err)
+	}
+
+	dial := func() (client.SurrealDBClient, error) {
+		return client.DialEndpoint(config.Endpoint, tlsConfig)
+	}
+
+	pool := client.NewPool(&config, dial)
+
+	var sessions *client.Sessions
+	if config.OAuthPassThru {
+		sessions = client.NewSessions(&config, dial)
 	}
 
-	client := client.Use(db)
+	client := client.Use(pool)
 
 	_, err = client.Connect(&config)
 	if err != nil {
+		pool.Close()
 		return nil, errorsource.DownstreamError(fmt.Errorf("unable to connect to database: %w", err), false)
 	}
 
-	return slo.NewMetricsWrapper(NewDatasourceInstance(client, &config), dsiConfig), nil
+	ds := NewDatasourceInstance(client, &config)
+
+	if sessions != nil {
+		ds.ForwardIdentity(sessions)
+	}
+
+	return &Instance{
+		MetricsWrapper: slo.NewMetricsWrapper(ds, dsiConfig),
+		datasource:     ds,
+	}, nil
+}
+
+// ForwardIdentity makes queries and resource calls run as the Grafana user,
+// using a session of the user authenticated with the OAuth ID token forwarded
+// by Grafana.
+func (d *SurrealDatasource) ForwardIdentity(sessions *client.Sessions) {
+	d.sessions = sessions
+}
+
+// userClient returns the client running the queries of the user of ctx, who is
+// authenticated with idToken when their identity is forwarded. Requests without
+// a token are rejected rather than run with the datasource credentials.
+func (d *SurrealDatasource) userClient(ctx context.Context, idToken string) (*client.Client, error) {
+	if d.sessions == nil {
+		return d.client, nil
+	}
+
+	idToken = strings.TrimPrefix(idToken, "Bearer ")
+	if idToken == "" {
+		return nil, errNoIDToken
+	}
+
+	// users without a login, e.g. when the request was not made by a user, are
+	// told apart by their token
+	key := idToken
+	if user := backend.UserFromContext(ctx); user != nil && user.Login != "" {
+		key = user.Login
+	}
+
+	return d.sessions.Get(key, idToken)
 }
 
 // Dispose cleans up the datasource instance resources.
 func (d *SurrealDatasource) Dispose() {
-	// Clean up datasource instance resources.
-	// d.db.Close()
+	if d.sessions != nil {
+		d.sessions.Close()
+	}
+	d.client.Close()
 }
 
 // QueryData handles multiple queries and returns multiple responses.
 // req contains the queries []DataQuery (where each query contains RefID as a unique identifier).
 // The QueryDataResponse contains a map of RefID to the response for each query, and each response
-// contains Frames ([]*Frame).
+// contains Frames ([]*Frame). Queries run in parallel, up to the configured number of concurrent
+// queries.
 func (d *SurrealDatasource) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
 	response := backend.NewQueryDataResponse()
 
+	c, err := d.userClient(ctx, req.GetHTTPHeader(backend.OAuthIdentityIDTokenHeaderName))
+	if err != nil {
+		for _, query := range req.Queries {
+			response.Responses[query.RefID] = backend.ErrDataResponseWithSource(backend.StatusUnauthorized, backend.ErrorSourceDownstream, fmt.Sprintf("identity: %v", err.Error()))
+		}
+		return response, nil
+	}
+
 	var mutex sync.Mutex
 	var wg sync.WaitGroup
 
@@ -82,8 +225,10 @@ func (d *SurrealDatasource) QueryData(ctx context.Context, req *backend.QueryDat
 		go func(ctx context.Context, pluginCtx backend.PluginContext, q backend.DataQuery) {
 			defer wg.Done()
 
+			res := d.runQuery(ctx, c, q)
+
 			mutex.Lock()
-			response.Responses[q.RefID] = d.CreateDataResponse(ctx, q)
+			response.Responses[q.RefID] = res
 			mutex.Unlock()
 		}(ctx, req.PluginContext, query)
 	}
@@ -93,6 +238,28 @@ func (d *SurrealDatasource) QueryData(ctx context.Context, req *backend.QueryDat
 	return response, nil
 }
 
+// runQuery creates the response of a query once a slot is available, or
+// returns an error response if ctx is done while waiting for a slot: a timeout
+// when its deadline is exceeded, and a cancellation otherwise.
+func (d *SurrealDatasource) runQuery(ctx context.Context, c *client.Client, query backend.DataQuery) backend.DataResponse {
+	select {
+	case d.slots <- struct{}{}:
+		defer func() { <-d.slots }()
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return backend.ErrDataResponseWithSource(backend.StatusTimeout, backend.ErrorSourcePlugin, fmt.Sprintf("query: %v", ctx.Err()))
+		}
+		return backend.ErrDataResponseWithSource(statusCanceled, backend.ErrorSourceDownstream, fmt.Sprintf("query: %v", ctx.Err()))
+	}
+
+	return d.createDataResponse(ctx, c, query)
+}
+
+// CallResource handles the schema introspection requests of the query editor.
+func (d *SurrealDatasource) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	return d.resourceHandler.CallResource(ctx, req, sender)
+}
+
 // CheckHealth handles health checks sent from Grafana to the plugin.
 // The main use case for these health checks is the test button on the
 // datasource configuration page which allows users to verify that
@@ -108,8 +275,19 @@ func (d *SurrealDatasource) CheckHealth(ctx context.Context, req *backend.CheckH
 		message = fmt.Sprintf("error while checking database health: %v", err)
 	}
 
+	reconnects := d.client.Reconnects()
+	if reconnects > 0 {
+		message = fmt.Sprintf("%s (reconnected %d times)", message, reconnects)
+	}
+
+	details, err := json.Marshal(map[string]interface{}{"reconnects": reconnects})
+	if err != nil {
+		return nil, err
+	}
+
 	return &backend.CheckHealthResult{
-		Status:  status,
-		Message: message,
+		Status:      status,
+		Message:     message,
+		JSONDetails: details,
 	}, nil
 }
@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/grafana-labs/surrealdb-datasource/internal/mocks"
 	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
@@ -56,6 +59,223 @@ func TestQueryData(t *testing.T) {
 	}
 }
 
+func TestQueryData_Concurrency(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	release := make(chan struct{})
+
+	blockingMock := mocks.MockSurrealDBClient{
+		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+			mu.Lock()
+			running++
+			maxRunning = max(maxRunning, running)
+			mu.Unlock()
+
+			<-release
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+
+			return []interface{}{
+				map[string]interface{}{"status": "OK", "result": []interface{}{}, "time": "1ms"},
+			}, nil
+		},
+	}
+
+	limitedConfig := config
+	limitedConfig.MaxConcurrentQueries = 2
+
+	datasource := plugin.NewDatasourceInstance(client.Use(&blockingMock), &limitedConfig)
+
+	var queries []backend.DataQuery
+	for i := 0; i < 6; i++ {
+		queries = append(queries, backend.DataQuery{RefID: fmt.Sprintf("query%d", i), JSON: []byte(`{"rawSql": "SELECT * FROM metrics"}`)})
+	}
+
+	done := make(chan *backend.QueryDataResponse)
+	go func() {
+		response, _ := datasource.QueryData(context.Background(), &backend.QueryDataRequest{Queries: queries})
+		done <- response
+	}()
+
+	// let the queries start before releasing them one at a time
+	time.Sleep(50 * time.Millisecond)
+	for range queries {
+		release <- struct{}{}
+	}
+
+	response := <-done
+
+	if maxRunning != 2 {
+		t.Errorf("expected 2 queries running at the same time, got %d", maxRunning)
+	}
+	for _, query := range queries {
+		if res := response.Responses[query.RefID]; res.Error != nil {
+			t.Errorf("unexpected error for %s: %s", query.RefID, res.Error)
+		}
+	}
+}
+
+func TestQueryData_CanceledWhileWaiting(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	blockingMock := mocks.MockSurrealDBClient{
+		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+			<-release
+			return []interface{}{}, nil
+		},
+	}
+
+	limitedConfig := config
+	limitedConfig.MaxConcurrentQueries = 1
+
+	datasource := plugin.NewDatasourceInstance(client.Use(&blockingMock), &limitedConfig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	response, err := datasource.QueryData(ctx, &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{RefID: "A", JSON: []byte(`{"rawSql": "SELECT * FROM metrics"}`)},
+			{RefID: "B", JSON: []byte(`{"rawSql": "SELECT * FROM metrics"}`)},
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, refID := range []string{"A", "B"} {
+		if response.Responses[refID].Error == nil {
+			t.Errorf("expected %s to fail when the context is done", refID)
+		}
+		if status := response.Responses[refID].Status; status != backend.StatusTimeout {
+			t.Errorf("expected %s to time out, got status %v", refID, status)
+		}
+	}
+}
+
+func TestQueryData_CanceledWhileWaitingForSlot(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	running := make(chan struct{}, 1)
+	blockingMock := mocks.MockSurrealDBClient{
+		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+			running <- struct{}{}
+			<-release
+			return []interface{}{}, nil
+		},
+	}
+
+	limitedConfig := config
+	limitedConfig.MaxConcurrentQueries = 1
+
+	datasource := plugin.NewDatasourceInstance(client.Use(&blockingMock), &limitedConfig)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		// the first query holds the only slot while the second one waits
+		<-running
+		cancel()
+	}()
+
+	response, err := datasource.QueryData(ctx, &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{RefID: "A", JSON: []byte(`{"rawSql": "SELECT * FROM metrics"}`)},
+			{RefID: "B", JSON: []byte(`{"rawSql": "SELECT * FROM metrics"}`)},
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// the first query is canceled while running, the second while waiting
+	for _, refID := range []string{"A", "B"} {
+		res := response.Responses[refID]
+		if res.Error == nil || res.Error.Error() != "query: context canceled" {
+			t.Errorf("expected %s to be canceled, got %v", refID, res.Error)
+		}
+		if res.Status != 499 {
+			t.Errorf("expected %s to be reported as canceled, got status %v", refID, res.Status)
+		}
+	}
+}
+
+func TestQueryData_ForwardIdentity(t *testing.T) {
+	var mu sync.Mutex
+	tokens := map[string]int{}
+
+	dial := func() (client.SurrealDBClient, error) {
+		var token string
+
+		return &mocks.MockSurrealDBClient{
+			AuthenticateFunc: func(tok string) (interface{}, error) {
+				token = tok
+				return nil, nil
+			},
+			UseFunc: func(namespace string, database string) (interface{}, error) {
+				return nil, nil
+			},
+			QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+				mu.Lock()
+				tokens[token]++
+				mu.Unlock()
+				return []interface{}{map[string]interface{}{"status": "OK", "result": []interface{}{}}}, nil
+			},
+			CloseFunc: func() {},
+		}, nil
+	}
+
+	datasource := plugin.NewDatasourceInstance(client.Use(&mock), &config)
+	sessions := client.NewSessions(&config, dial)
+	defer sessions.Close()
+	datasource.ForwardIdentity(sessions)
+
+	for _, user := range []string{"alice", "bob", "alice"} {
+		req := &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{"rawSql": "SELECT * FROM metrics"}`)}},
+		}
+		req.SetHTTPHeader(backend.OAuthIdentityIDTokenHeaderName, user+"-token")
+
+		ctx := backend.WithUser(context.Background(), &backend.User{Login: user})
+
+		response, err := datasource.QueryData(ctx, req)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := response.Responses["A"].Error; err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	if tokens["alice-token"] != 2 || tokens["bob-token"] != 1 {
+		t.Errorf("expected the queries to run with the tokens of their users, got %v", tokens)
+	}
+}
+
+func TestQueryData_ForwardIdentityWithoutToken(t *testing.T) {
+	datasource := plugin.NewDatasourceInstance(client.Use(&mock), &config)
+	sessions := client.NewSessions(&config, func() (client.SurrealDBClient, error) {
+		return nil, errors.New("unexpected connection")
+	})
+	defer sessions.Close()
+	datasource.ForwardIdentity(sessions)
+
+	response, err := datasource.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{"rawSql": "SELECT * FROM metrics"}`)}},
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if response.Responses["A"].Status != backend.StatusUnauthorized {
+		t.Errorf("expected status unauthorized, got %v", response.Responses["A"].Status)
+	}
+}
+
 func TestCheckHealth_Success(t *testing.T) {
 	successMock := mocks.MockSurrealDBClient{
 		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
@@ -93,3 +313,46 @@ func TestCheckHealth_Error(t *testing.T) {
 		t.Error("expected result to be non-nil")
 	}
 }
+
+func TestCheckHealth_Reconnects(t *testing.T) {
+	queries := 0
+
+	reconnectMock := mocks.MockSurrealDBClient{
+		SigninFunc: func(vars interface{}) (interface{}, error) {
+			return nil, nil
+		},
+		UseFunc: func(namespace string, database string) (interface{}, error) {
+			return nil, nil
+		},
+		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+			queries++
+			if queries == 1 {
+				return nil, client.ErrClosed
+			}
+			return []interface{}{}, nil
+		},
+	}
+
+	c := client.Use(&reconnectMock)
+	if _, err := c.Connect(&config); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	datasource := plugin.NewDatasourceInstance(c, &config)
+	result, err := datasource.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Status != backend.HealthStatusOk {
+		t.Errorf("expected status OK, got %s: %s", result.Status, result.Message)
+	}
+
+	var details map[string]int
+	if err := json.Unmarshal(result.JSONDetails, &details); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if details["reconnects"] != 1 {
+		t.Errorf("expected 1 reconnect, got %d", details["reconnects"])
+	}
+}
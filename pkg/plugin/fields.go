@@ -0,0 +1,186 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// columnKind is the type inferred from the values of a single column.
+type columnKind int
+
+const (
+	kindNull columnKind = iota
+	kindBool
+	kindInt
+	kindFloat
+	kindTime
+	kindString
+	kindJSON
+	kindMixed
+)
+
+// columnValue is a single decoded value of a column together with its kind.
+type columnValue struct {
+	kind  columnKind
+	value interface{}
+	raw   json.RawMessage
+}
+
+// decodeValue decodes a raw JSON value returned by SurrealDB into a Go value,
+// recognising SurrealDB datetimes which are serialised as RFC3339 strings.
+func decodeValue(raw json.RawMessage) columnValue {
+	trimmed := bytes.TrimSpace(raw)
+
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return columnValue{kind: kindNull}
+	}
+
+	switch trimmed[0] {
+	case 't', 'f':
+		var b bool
+		if err := json.Unmarshal(trimmed, &b); err == nil {
+			return columnValue{kind: kindBool, value: b, raw: trimmed}
+		}
+	case '"':
+		var s string
+		if err := json.Unmarshal(trimmed, &s); err == nil {
+			if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				return columnValue{kind: kindTime, value: t, raw: trimmed}
+			}
+			return columnValue{kind: kindString, value: s, raw: trimmed}
+		}
+	case '{', '[':
+		return columnValue{kind: kindJSON, value: json.RawMessage(trimmed), raw: trimmed}
+	default:
+		n := json.Number(trimmed)
+		if i, err := n.Int64(); err == nil {
+			return columnValue{kind: kindInt, value: i, raw: trimmed}
+		}
+		if f, err := n.Float64(); err == nil {
+			return columnValue{kind: kindFloat, value: f, raw: trimmed}
+		}
+	}
+
+	return columnValue{kind: kindJSON, value: json.RawMessage(trimmed), raw: trimmed}
+}
+
+// mergeKinds returns the kind that can represent values of both a and b.
+func mergeKinds(a, b columnKind) columnKind {
+	switch {
+	case a == b:
+		return a
+	case a == kindNull:
+		return b
+	case b == kindNull:
+		return a
+	case (a == kindInt && b == kindFloat) || (a == kindFloat && b == kindInt):
+		return kindFloat
+	case (a == kindTime && b == kindString) || (a == kindString && b == kindTime):
+		return kindString
+	}
+
+	return kindMixed
+}
+
+// newTypedField creates a data field from the raw JSON values of a column. The
+// field type is inferred from the values; when some values are null the nullable
+// variant of the type is used. Columns mixing incompatible types fall back to a
+// string field holding the JSON representation of each value.
+func newTypedField(name string, raws []json.RawMessage) *data.Field {
+	values := make([]columnValue, len(raws))
+
+	kind := kindNull
+	nullable := false
+
+	for i, raw := range raws {
+		values[i] = decodeValue(raw)
+
+		if values[i].kind == kindNull {
+			nullable = true
+			continue
+		}
+
+		kind = mergeKinds(kind, values[i].kind)
+	}
+
+	return newKindField(name, values, kind, nullable)
+}
+
+// newKindField creates a data field of the type of a kind from decoded values.
+// Values the kind cannot represent are null, which makes the field nullable.
+func newKindField(name string, values []columnValue, kind columnKind, nullable bool) *data.Field {
+	for i, v := range values {
+		if v.kind != kindNull && mergeKinds(kind, v.kind) != kind {
+			values[i] = columnValue{kind: kindNull}
+			nullable = true
+		}
+	}
+
+	switch kind {
+	case kindBool:
+		return buildField(name, values, nullable, func(v columnValue) bool {
+			return v.value.(bool)
+		})
+	case kindInt:
+		return buildField(name, values, nullable, func(v columnValue) int64 {
+			return v.value.(int64)
+		})
+	case kindFloat:
+		return buildField(name, values, nullable, func(v columnValue) float64 {
+			if i, ok := v.value.(int64); ok {
+				return float64(i)
+			}
+			return v.value.(float64)
+		})
+	case kindTime:
+		return buildField(name, values, nullable, func(v columnValue) time.Time {
+			return v.value.(time.Time)
+		})
+	case kindString:
+		return buildField(name, values, nullable, func(v columnValue) string {
+			if s, ok := v.value.(string); ok {
+				return s
+			}
+			// datetimes in a string column keep their original representation
+			var s string
+			_ = json.Unmarshal(v.raw, &s)
+			return s
+		})
+	case kindJSON:
+		return buildField(name, values, nullable, func(v columnValue) json.RawMessage {
+			return v.raw
+		})
+	default:
+		// null-only and mixed columns are represented as strings
+		return buildField(name, values, true, func(v columnValue) string {
+			if s, ok := v.value.(string); ok {
+				return s
+			}
+			return string(v.raw)
+		})
+	}
+}
+
+// buildField creates a field of type T (or *T when nullable) from decoded values.
+func buildField[T any](name string, values []columnValue, nullable bool, convert func(columnValue) T) *data.Field {
+	if !nullable {
+		vals := make([]T, len(values))
+		for i, v := range values {
+			vals[i] = convert(v)
+		}
+		return data.NewField(name, nil, vals)
+	}
+
+	vals := make([]*T, len(values))
+	for i, v := range values {
+		if v.kind == kindNull {
+			continue
+		}
+		converted := convert(v)
+		vals[i] = &converted
+	}
+	return data.NewField(name, nil, vals)
+}
@@ -0,0 +1,219 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"strings"
+)
+
+// frameOptions defines how the rows of the results of a query are shaped into frames.
+type frameOptions struct {
+	// maxRows is the maximum number of rows per statement, unlimited when zero.
+	maxRows int
+	// flattenDepth is the number of levels of nested objects flattened into
+	// dotted columns, e.g. `address.city`.
+	flattenDepth int
+	arrayMode    ArrayMode
+	// splitRecordIDs adds the table and the id of record IDs as columns.
+	splitRecordIDs bool
+	// keepEmpty adds an empty frame for the statements without rows.
+	keepEmpty bool
+	// datasource is the datasource the data links of record IDs query, no
+	// links are added when its uid is empty.
+	datasource datasourceRef
+	// skipStatements is the number of statements added before those of the
+	// query, whose results are left out.
+	skipStatements int
+}
+
+// shapeRows flattens the nested objects of the rows and handles their arrays
+// according to the options.
+func shapeRows(rs rowSet, opts frameOptions) rowSet {
+	rs = flattenRows(rs, opts.flattenDepth)
+
+	switch opts.arrayMode {
+	case ArrayModeJoin:
+		joinArrays(rs.rows)
+	case ArrayModeExplode:
+		// exploded objects are flattened like the other objects of the rows
+		rs = flattenRows(explodeArrays(rs), opts.flattenDepth)
+	}
+
+	return rs
+}
+
+// flattenRows replaces the nested objects of the rows with a column per key,
+// named after the path to the key, e.g. `address.city`, up to depth levels.
+// The columns of an object take its place, in the order of its keys. Paths
+// which are objects in some rows and null in others only have the columns of
+// the object, with nulls in the other rows.
+func flattenRows(rs rowSet, depth int) rowSet {
+	if depth <= 0 {
+		return rs
+	}
+
+	objects := map[string]bool{}
+	for _, row := range rs.rows {
+		for key, value := range row {
+			markObjects(objects, key, value, depth-strings.Count(key, "."))
+		}
+	}
+
+	if len(objects) == 0 {
+		return rs
+	}
+
+	flattened := rowSet{rows: make([]map[string]json.RawMessage, len(rs.rows))}
+
+	for i, row := range rs.rows {
+		flat := make(map[string]json.RawMessage, len(row))
+		var keys []string
+		for _, column := range rs.columns {
+			if value, ok := row[column]; ok {
+				keys = flattenValue(flat, keys, objects, column, value)
+			}
+		}
+		flattened.rows[i] = flat
+		flattened.columns = mergeColumns(flattened.columns, keys)
+	}
+
+	return flattened
+}
+
+// markObjects marks the paths of a value which are non-empty objects to be
+// flattened, up to depth levels.
+func markObjects(objects map[string]bool, key string, value json.RawMessage, depth int) {
+	value = bytes.TrimSpace(value)
+
+	if depth <= 0 || len(value) == 0 || value[0] != '{' {
+		return
+	}
+
+	// geometries are converted as a whole
+	if _, ok := parseGeometry(value); ok {
+		return
+	}
+
+	keys, object, err := unmarshalObject(value)
+	if err != nil || len(keys) == 0 {
+		return
+	}
+
+	objects[key] = true
+
+	for _, k := range keys {
+		markObjects(objects, key+"."+k, object[k], depth-1)
+	}
+}
+
+// flattenValue adds a value to a flattened row, or the keys of the value if it
+// is an object to be flattened, and returns the keys of the row in order.
+func flattenValue(flat map[string]json.RawMessage, keys []string, objects map[string]bool, key string, value json.RawMessage) []string {
+	value = bytes.TrimSpace(value)
+
+	if !objects[key] {
+		flat[key] = value
+		return append(keys, key)
+	}
+
+	if len(value) == 0 || bytes.Equal(value, []byte("null")) {
+		return keys
+	}
+
+	objectKeys, object, err := unmarshalObject(value)
+	if err != nil || len(objectKeys) == 0 {
+		flat[key] = value
+		return append(keys, key)
+	}
+
+	for _, k := range objectKeys {
+		keys = flattenValue(flat, keys, objects, key+"."+k, object[k])
+	}
+
+	return keys
+}
+
+// joinArrays replaces the arrays of the rows with their elements joined with
+// commas. Strings are joined without quotes, other elements as JSON.
+func joinArrays(rows []map[string]json.RawMessage) {
+	for _, row := range rows {
+		for key, value := range row {
+			elements, ok := arrayElements(value)
+			if !ok {
+				continue
+			}
+
+			parts := make([]string, len(elements))
+			for i, element := range elements {
+				var s string
+				if err := json.Unmarshal(element, &s); err == nil {
+					parts[i] = s
+				} else {
+					parts[i] = string(bytes.TrimSpace(element))
+				}
+			}
+
+			joined, _ := json.Marshal(strings.Join(parts, ", "))
+			row[key] = joined
+		}
+	}
+}
+
+// explodeArrays replaces each row with a row per element of its arrays. The
+// arrays of a row are exploded side by side, so the nth row holds the nth
+// element of each array, or null when an array is shorter; empty arrays become
+// nulls.
+func explodeArrays(rs rowSet) rowSet {
+	exploded := make([]map[string]json.RawMessage, 0, len(rs.rows))
+
+	for _, row := range rs.rows {
+		arrays := map[string][]json.RawMessage{}
+		n := 1
+
+		for _, key := range rs.columns {
+			value, ok := row[key]
+			if !ok {
+				continue
+			}
+			if elements, ok := arrayElements(value); ok {
+				arrays[key] = elements
+				n = max(n, len(elements))
+			}
+		}
+
+		if len(arrays) == 0 {
+			exploded = append(exploded, row)
+			continue
+		}
+
+		for i := 0; i < n; i++ {
+			r := maps.Clone(row)
+			for key, elements := range arrays {
+				if i < len(elements) {
+					r[key] = elements[i]
+				} else {
+					r[key] = json.RawMessage("null")
+				}
+			}
+			exploded = append(exploded, r)
+		}
+	}
+
+	return rowSet{columns: rs.columns, rows: exploded}
+}
+
+// arrayElements returns the elements of a value if it is an array.
+func arrayElements(value json.RawMessage) ([]json.RawMessage, bool) {
+	value = bytes.TrimSpace(value)
+	if len(value) == 0 || value[0] != '[' {
+		return nil, false
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal(value, &elements); err != nil {
+		return nil, false
+	}
+
+	return elements, true
+}
@@ -0,0 +1,200 @@
+package plugin_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/grafana-labs/surrealdb-datasource/internal/mocks"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/plugin"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// personMock returns people with nested addresses and arrays of tags.
+var personMock = mocks.MockSurrealDBClient{
+	QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+		return []interface{}{
+			map[string]interface{}{
+				"status": "OK",
+				"result": []interface{}{
+					map[string]interface{}{
+						"name":    "aaron",
+						"address": map[string]interface{}{"city": "London", "geo": map[string]interface{}{"country": "UK"}},
+						"tags":    []interface{}{"admin", "editor"},
+					},
+					map[string]interface{}{
+						"name":    "bea",
+						"address": map[string]interface{}{"city": "Paris", "geo": map[string]interface{}{"country": "FR"}},
+						"tags":    []interface{}{"viewer"},
+					},
+				},
+			},
+		}, nil
+	},
+}
+
+// fieldNames returns the names of the fields of a frame.
+func fieldNames(frame *data.Frame) []string {
+	names := make([]string, len(frame.Fields))
+	for i, field := range frame.Fields {
+		names[i] = field.Name
+	}
+	return names
+}
+
+func TestCreateDataResponse_Flatten(t *testing.T) {
+	cases := []struct {
+		name     string
+		json     string
+		expected []string
+	}{
+		{
+			name:     "no flattening",
+			json:     `{"rawSql": "SELECT * FROM person"}`,
+			expected: []string{"address", "name", "tags"},
+		},
+		{
+			name:     "one level",
+			json:     `{"rawSql": "SELECT * FROM person", "flattenDepth": 1}`,
+			expected: []string{"address.city", "address.geo", "name", "tags"},
+		},
+		{
+			name:     "two levels",
+			json:     `{"rawSql": "SELECT * FROM person", "flattenDepth": 2}`,
+			expected: []string{"address.city", "address.geo.country", "name", "tags"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := plugin.NewDatasourceInstance(client.Use(&personMock), &config)
+			response := ds.CreateDataResponse(context.TODO(), backend.DataQuery{RefID: "A", JSON: []byte(tt.json)})
+
+			if response.Error != nil {
+				t.Fatalf("unexpected error: %s", response.Error)
+			}
+
+			names := fieldNames(response.Frames[0])
+			if len(names) != len(tt.expected) {
+				t.Fatalf("expected fields %v, got %v", tt.expected, names)
+			}
+			for i := range names {
+				if names[i] != tt.expected[i] {
+					t.Errorf("expected fields %v, got %v", tt.expected, names)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestCreateDataResponse_ArrayModes(t *testing.T) {
+	t.Run("join", func(t *testing.T) {
+		ds := plugin.NewDatasourceInstance(client.Use(&personMock), &config)
+		response := ds.CreateDataResponse(context.TODO(), backend.DataQuery{
+			RefID: "A",
+			JSON:  []byte(`{"rawSql": "SELECT * FROM person", "arrayMode": "join"}`),
+		})
+
+		if response.Error != nil {
+			t.Fatalf("unexpected error: %s", response.Error)
+		}
+
+		tags, _ := response.Frames[0].FieldByName("tags")
+		if tags == nil || tags.Type() != data.FieldTypeString {
+			t.Fatalf("expected a string tags field, got %v", tags)
+		}
+		if tag := tags.At(0).(string); tag != "admin, editor" {
+			t.Errorf("expected joined tags, got %q", tag)
+		}
+	})
+
+	t.Run("explode", func(t *testing.T) {
+		ds := plugin.NewDatasourceInstance(client.Use(&personMock), &config)
+		response := ds.CreateDataResponse(context.TODO(), backend.DataQuery{
+			RefID: "A",
+			JSON:  []byte(`{"rawSql": "SELECT * FROM person", "arrayMode": "explode", "flattenDepth": 1}`),
+		})
+
+		if response.Error != nil {
+			t.Fatalf("unexpected error: %s", response.Error)
+		}
+
+		frame := response.Frames[0]
+		if rows := frame.Rows(); rows != 3 {
+			t.Fatalf("expected a row per tag, got %d rows", rows)
+		}
+
+		names, _ := frame.FieldByName("name")
+		tags, _ := frame.FieldByName("tags")
+		cities, _ := frame.FieldByName("address.city")
+		expected := [][3]string{{"aaron", "admin", "London"}, {"aaron", "editor", "London"}, {"bea", "viewer", "Paris"}}
+
+		for i, row := range expected {
+			if got := [3]string{names.At(i).(string), tags.At(i).(string), cities.At(i).(string)}; got != row {
+				t.Errorf("expected row %d to be %v, got %v", i, row, got)
+			}
+		}
+	})
+}
+
+func TestCreateDataResponse_ExplodeMaxRows(t *testing.T) {
+	arraysMock := mocks.MockSurrealDBClient{
+		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+			return json.RawMessage(`[{"status": "OK", "result": [
+				{"a": [1, 2, 3, 4, 5], "b": [1, 2, 3, 4, 5], "c": [1, 2, 3]}
+			]}]`), nil
+		},
+	}
+
+	cases := []struct {
+		name      string
+		json      string
+		rows      int
+		truncated bool
+	}{
+		{
+			name: "side by side",
+			json: `{"rawSql": "SELECT * FROM arrays", "arrayMode": "explode"}`,
+			rows: 5,
+		},
+		{
+			name:      "max rows",
+			json:      `{"rawSql": "SELECT * FROM arrays", "arrayMode": "explode", "maxRows": 2}`,
+			rows:      2,
+			truncated: true,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := plugin.NewDatasourceInstance(client.Use(&arraysMock), &config)
+			response := ds.CreateDataResponse(context.TODO(), backend.DataQuery{RefID: "A", JSON: []byte(tt.json)})
+
+			if response.Error != nil {
+				t.Fatalf("unexpected error: %s", response.Error)
+			}
+
+			frame := response.Frames[0]
+			if rows := frame.Rows(); rows != tt.rows {
+				t.Fatalf("expected %d rows, got %d", tt.rows, rows)
+			}
+			if truncated := frame.Meta != nil && len(frame.Meta.Notices) > 0; truncated != tt.truncated {
+				t.Errorf("expected truncated to be %v, got notices %v", tt.truncated, frame.Meta)
+			}
+
+			a, _ := frame.FieldByName("a")
+			c, _ := frame.FieldByName("c")
+			for i := 0; i < frame.Rows(); i++ {
+				if v, _ := a.ConcreteAt(i); v != int64(i+1) {
+					t.Errorf("expected a to be %d in row %d, got %v", i+1, i, v)
+				}
+			}
+			if _, ok := c.ConcreteAt(frame.Rows() - 1); ok && tt.rows == 5 {
+				t.Error("expected c to be null past its last element")
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package plugin
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// numberRegex matches values formatted as SurrealQL numbers.
+var numberRegex = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// ValueType is the declared type of the values of a variable reference or of an
+// ad-hoc filter, which are strings unless declared otherwise.
+type ValueType string
+
+const (
+	// ValueTypeString formats values as strings.
+	ValueTypeString ValueType = ""
+	// ValueTypeNumber keeps values formatted as numbers as they are.
+	ValueTypeNumber ValueType = "number"
+	// ValueTypeRecord keeps values formatted as record IDs as they are.
+	ValueTypeRecord ValueType = "record"
+)
+
+// TemplateVariable is the value of a dashboard variable referenced by a query,
+// which is interpolated by the backend rather than by Grafana.
+type TemplateVariable struct {
+	Values []string `json:"values"`
+	// Multi is true for variables which can have several values, which are
+	// always interpolated as arrays, even when a single value is selected.
+	Multi bool `json:"multi,omitempty"`
+}
+
+// interpolateVariables replaces the references to template variables, `$name`,
+// `${name}` or `${name:type}`, by their values formatted as SurrealQL literals
+// of the type of the reference, e.g. `${threshold:number}`. All variables are
+// replaced in a single pass, so values are never interpolated twice.
+func interpolateVariables(sql string, variables map[string]TemplateVariable) string {
+	if len(variables) == 0 {
+		return sql
+	}
+
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	// longer names first, so that `$hosts` is not matched as `$host`
+	sort.Slice(names, func(i, j int) bool {
+		return len(names[i]) > len(names[j])
+	})
+
+	alternatives := strings.Join(names, "|")
+	types := string(ValueTypeNumber) + "|" + string(ValueTypeRecord)
+	rgx := regexp.MustCompile(`\$\{(` + alternatives + `)(?::(` + types + `))?\}|\$(` + alternatives + `)\b`)
+
+	return rgx.ReplaceAllStringFunc(sql, func(match string) string {
+		m := rgx.FindStringSubmatch(match)
+		if m[1] != "" {
+			return formatVariable(variables[m[1]], ValueType(m[2]))
+		}
+		return formatVariable(variables[m[3]], ValueTypeString)
+	})
+}
+
+// formatVariable formats the values of a variable as a SurrealQL literal:
+// an array for multi-value variables, a single value otherwise.
+//
+//	["a", "b"] => ['a', 'b']
+func formatVariable(variable TemplateVariable, typ ValueType) string {
+	if !variable.Multi {
+		if len(variable.Values) == 0 {
+			return "NONE"
+		}
+		return formatValue(variable.Values[0], typ)
+	}
+
+	values := make([]string, len(variable.Values))
+	for i, v := range variable.Values {
+		values[i] = formatValue(v, typ)
+	}
+
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// formatValue formats a value as a SurrealQL literal. Numbers and record IDs
+// are kept as they are when the value is declared as such, anything else
+// becomes an escaped string, so `1.10` or `region:eu` stay strings unless
+// declared otherwise. Record IDs with array or object ids stay strings too.
+//
+//	42, number => 42
+//	host:abc, record => host:abc
+//	42 => '42'
+//	it's => 'it\'s'
+func formatValue(v string, typ ValueType) string {
+	switch {
+	case typ == ValueTypeNumber && numberRegex.MatchString(v):
+		return v
+	case typ == ValueTypeRecord && isRecordIDLiteral(v):
+		return v
+	}
+
+	return quoteString(v)
+}
+
+// quoteString formats s as a single quoted SurrealQL string.
+func quoteString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+
+	return "'" + s + "'"
+}
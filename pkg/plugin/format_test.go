@@ -0,0 +1,155 @@
+package plugin_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/grafana-labs/surrealdb-datasource/internal/mocks"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/plugin"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestTemplateVariables(t *testing.T) {
+	cases := []struct {
+		name      string
+		input     string
+		variables map[string]plugin.TemplateVariable
+		expected  string
+	}{
+		{
+			name:      "string",
+			input:     "SELECT * FROM host WHERE name = $host",
+			variables: map[string]plugin.TemplateVariable{"host": {Values: []string{"web"}}},
+			expected:  "SELECT * FROM host WHERE name = 'web'",
+		},
+		{
+			name:      "string with quotes and backslashes",
+			input:     "SELECT * FROM host WHERE name = $host",
+			variables: map[string]plugin.TemplateVariable{"host": {Values: []string{`it's a \ test`}}},
+			expected:  `SELECT * FROM host WHERE name = 'it\'s a \\ test'`,
+		},
+		{
+			name:      "number",
+			input:     "SELECT * FROM metrics WHERE value > ${threshold:number}",
+			variables: map[string]plugin.TemplateVariable{"threshold": {Values: []string{"-1.5e3"}}},
+			expected:  "SELECT * FROM metrics WHERE value > -1.5e3",
+		},
+		{
+			name:      "invalid number",
+			input:     "SELECT * FROM metrics WHERE value > ${threshold:number}",
+			variables: map[string]plugin.TemplateVariable{"threshold": {Values: []string{"1 OR true"}}},
+			expected:  "SELECT * FROM metrics WHERE value > '1 OR true'",
+		},
+		{
+			name:      "record ID",
+			input:     "SELECT * FROM ${host:record}",
+			variables: map[string]plugin.TemplateVariable{"host": {Values: []string{"host:⟨web-1⟩"}}},
+			expected:  "SELECT * FROM host:⟨web-1⟩",
+		},
+		{
+			name:      "record ID with an escaped closing bracket",
+			input:     "SELECT * FROM metrics WHERE a = ${a:record} AND b = $b",
+			variables: map[string]plugin.TemplateVariable{"a": {Values: []string{`h:⟨a\⟩`}}, "b": {Values: []string{"⟩; DELETE person; --"}}},
+			expected:  `SELECT * FROM metrics WHERE a = 'h:⟨a\\⟩' AND b = '⟩; DELETE person; --'`,
+		},
+		{
+			name:      "record ID with an array id",
+			input:     "SELECT * FROM ${host:record}",
+			variables: map[string]plugin.TemplateVariable{"host": {Values: []string{"host:[<future> { 1 }]"}}},
+			expected:  "SELECT * FROM 'host:[<future> { 1 }]'",
+		},
+		{
+			name:      "untyped number and record ID",
+			input:     "SELECT * FROM metrics WHERE version = $version AND region = $region",
+			variables: map[string]plugin.TemplateVariable{"version": {Values: []string{"1.10"}}, "region": {Values: []string{"region:eu"}}},
+			expected:  "SELECT * FROM metrics WHERE version = '1.10' AND region = 'region:eu'",
+		},
+		{
+			name:      "multiple values",
+			input:     "SELECT * FROM metrics WHERE host INSIDE ${host:record}",
+			variables: map[string]plugin.TemplateVariable{"host": {Values: []string{"host:a", "b", "3"}, Multi: true}},
+			expected:  "SELECT * FROM metrics WHERE host INSIDE [host:a, 'b', '3']",
+		},
+		{
+			name:      "multi-value with single value",
+			input:     "SELECT * FROM metrics WHERE host INSIDE $host",
+			variables: map[string]plugin.TemplateVariable{"host": {Values: []string{"a"}, Multi: true}},
+			expected:  "SELECT * FROM metrics WHERE host INSIDE ['a']",
+		},
+		{
+			name:      "multi-value without value",
+			input:     "SELECT * FROM metrics WHERE host INSIDE $host",
+			variables: map[string]plugin.TemplateVariable{"host": {Values: []string{}, Multi: true}},
+			expected:  "SELECT * FROM metrics WHERE host INSIDE []",
+		},
+		{
+			name:      "braces syntax",
+			input:     "SELECT * FROM metrics WHERE host = ${host}",
+			variables: map[string]plugin.TemplateVariable{"host": {Values: []string{"a"}}},
+			expected:  "SELECT * FROM metrics WHERE host = 'a'",
+		},
+		{
+			name:  "similar names",
+			input: "SELECT * FROM metrics WHERE host = $host AND host INSIDE $hosts",
+			variables: map[string]plugin.TemplateVariable{
+				"host":  {Values: []string{"a"}},
+				"hosts": {Values: []string{"b", "c"}, Multi: true},
+			},
+			expected: "SELECT * FROM metrics WHERE host = 'a' AND host INSIDE ['b', 'c']",
+		},
+		{
+			name:  "value referencing another variable",
+			input: "SELECT * FROM metrics WHERE host = $host AND region = $region",
+			variables: map[string]plugin.TemplateVariable{
+				"host":   {Values: []string{"$region"}},
+				"region": {Values: []string{"eu"}},
+			},
+			expected: "SELECT * FROM metrics WHERE host = '$region' AND region = 'eu'",
+		},
+		{
+			name:      "value looking like a macro",
+			input:     "SELECT * FROM metrics WHERE note = $note AND $__timeFrom(time)",
+			variables: map[string]plugin.TemplateVariable{"note": {Values: []string{"x $__timeFrom y"}}},
+			expected:  "SELECT * FROM metrics WHERE note = 'x $__timeFrom y' AND time >= d'0001-01-01T00:00:00Z'",
+		},
+		{
+			name:      "unknown variable",
+			input:     "SELECT * FROM metrics WHERE region = $region",
+			variables: map[string]plugin.TemplateVariable{"host": {Values: []string{"a"}}},
+			expected:  "SELECT * FROM metrics WHERE region = $region",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var sql string
+
+			mock := mocks.MockSurrealDBClient{
+				QueryFunc: func(s string, vars interface{}) (interface{}, error) {
+					sql = s
+					return []interface{}{
+						map[string]interface{}{"status": "OK", "result": []interface{}{}, "time": "1ms"},
+					}, nil
+				},
+			}
+
+			ds := plugin.NewDatasourceInstance(client.Use(&mock), &config)
+
+			model, err := json.Marshal(plugin.SurrealQuery{RawSQL: tt.input, Variables: tt.variables})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			response := ds.CreateDataResponse(context.TODO(), backend.DataQuery{RefID: "A", JSON: model})
+
+			if response.Error != nil {
+				t.Fatalf("unexpected error: %s", response.Error)
+			}
+			if sql != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, sql)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// latitudeColumnName and longitudeColumnName are the names of the columns
+	// of the coordinates of points, which the Geomap panel finds by itself.
+	latitudeColumnName  = "latitude"
+	longitudeColumnName = "longitude"
+)
+
+// geometryTypes are the WKT names of the GeoJSON geometry types returned by
+// SurrealDB for its geometry values.
+var geometryTypes = map[string]string{
+	"Point":              "POINT",
+	"LineString":         "LINESTRING",
+	"Polygon":            "POLYGON",
+	"MultiPoint":         "MULTIPOINT",
+	"MultiLineString":    "MULTILINESTRING",
+	"MultiPolygon":       "MULTIPOLYGON",
+	"GeometryCollection": "GEOMETRYCOLLECTION",
+}
+
+// geometry is a GeoJSON geometry.
+type geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates,omitempty"`
+	Geometries  []geometry      `json:"geometries,omitempty"`
+}
+
+// parseGeometry returns the geometry of a GeoJSON geometry object, and whether
+// the value is one. Objects with other keys than those of geometries, such as
+// records with a `type` field, are not geometries.
+func parseGeometry(value json.RawMessage) (geometry, bool) {
+	value = bytes.TrimSpace(value)
+	if len(value) == 0 || value[0] != '{' {
+		return geometry{}, false
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(value, &object); err != nil || len(object) != 2 {
+		return geometry{}, false
+	}
+
+	var g geometry
+	if err := json.Unmarshal(value, &g); err != nil {
+		return geometry{}, false
+	}
+
+	if _, ok := geometryTypes[g.Type]; !ok {
+		return geometry{}, false
+	}
+	if g.Type == "GeometryCollection" {
+		_, ok := object["geometries"]
+		return g, ok
+	}
+
+	return g, len(g.Coordinates) > 0
+}
+
+// convertGeometries converts the columns of geometries so the Geomap panel can
+// show them. Columns of points are replaced by `latitude` and `longitude`
+// columns, named after the column, e.g. `position.latitude`, when the rows have
+// several columns of points. Columns of other geometries, or of a mix of
+// geometries, hold their WKT representation, e.g. `POLYGON ((0 0, 1 0, 1 1, 0 0))`.
+func convertGeometries(rs rowSet) rowSet {
+	var points, shapes []string
+
+	for _, column := range rs.columns {
+		switch geometryColumn(rs.rows, column) {
+		case "Point":
+			points = append(points, column)
+		case "":
+			// not a column of geometries
+		default:
+			shapes = append(shapes, column)
+		}
+	}
+
+	for _, column := range shapes {
+		for _, row := range rs.rows {
+			if g, ok := parseGeometry(row[column]); ok {
+				row[column], _ = json.Marshal(g.wkt())
+			}
+		}
+	}
+
+	for _, column := range points {
+		latColumn, lonColumn := column+"."+latitudeColumnName, column+"."+longitudeColumnName
+		if len(points) == 1 && !slices.Contains(rs.columns, latitudeColumnName) && !slices.Contains(rs.columns, longitudeColumnName) {
+			latColumn, lonColumn = latitudeColumnName, longitudeColumnName
+		}
+
+		for _, row := range rs.rows {
+			g, ok := parseGeometry(row[column])
+			delete(row, column)
+			if !ok {
+				continue
+			}
+
+			var coordinates []float64
+			if err := json.Unmarshal(g.Coordinates, &coordinates); err != nil || len(coordinates) < 2 {
+				continue
+			}
+			row[latColumn], row[lonColumn] = floatJSON(coordinates[1]), floatJSON(coordinates[0])
+		}
+
+		i := slices.Index(rs.columns, column)
+		rs.columns = slices.Replace(rs.columns, i, i+1, latColumn, lonColumn)
+	}
+
+	return rs
+}
+
+// geometryColumn returns the type of the geometries of a column, "Geometry"
+// when it has several types, or "" when it has other values than geometries
+// and nulls.
+func geometryColumn(rows []map[string]json.RawMessage, column string) string {
+	kind := ""
+
+	for _, row := range rows {
+		if decodeValue(row[column]).kind == kindNull {
+			continue
+		}
+
+		g, ok := parseGeometry(row[column])
+		switch {
+		case !ok:
+			return ""
+		case kind == "":
+			kind = g.Type
+		case kind != g.Type:
+			kind = "Geometry"
+		}
+	}
+
+	return kind
+}
+
+// wkt returns the WKT representation of the geometry.
+func (g geometry) wkt() string {
+	name := geometryTypes[g.Type]
+
+	if g.Type == "GeometryCollection" {
+		parts := make([]string, len(g.Geometries))
+		for i, child := range g.Geometries {
+			parts[i] = child.wkt()
+		}
+		return name + " (" + strings.Join(parts, ", ") + ")"
+	}
+
+	var coordinates interface{}
+	if err := json.Unmarshal(g.Coordinates, &coordinates); err != nil {
+		return name + " EMPTY"
+	}
+
+	text := wktCoordinates(coordinates)
+	if g.Type == "Point" && text != "EMPTY" {
+		// the coordinates of points are not nested
+		return name + " (" + text + ")"
+	}
+
+	return name + " " + text
+}
+
+// wktCoordinates returns the WKT representation of GeoJSON coordinates:
+// positions are space separated numbers, and lists of positions, rings or
+// polygons are comma separated in parentheses.
+func wktCoordinates(coordinates interface{}) string {
+	values, ok := coordinates.([]interface{})
+	if !ok || len(values) == 0 {
+		return "EMPTY"
+	}
+
+	parts := make([]string, len(values))
+	for i, v := range values {
+		if f, ok := v.(float64); ok {
+			parts[i] = strconv.FormatFloat(f, 'f', -1, 64)
+			continue
+		}
+		parts[i] = wktCoordinates(v)
+	}
+
+	if _, ok := values[0].(float64); ok {
+		return strings.Join(parts, " ")
+	}
+
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// floatJSON returns f as a JSON number with a decimal point, so coordinates
+// without a fractional part are still decoded as floats.
+func floatJSON(f float64) json.RawMessage {
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+
+	return json.RawMessage(s)
+}
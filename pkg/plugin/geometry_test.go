@@ -0,0 +1,117 @@
+package plugin_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/grafana-labs/surrealdb-datasource/internal/mocks"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/plugin"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestCreateDataResponse_Geometry(t *testing.T) {
+	cases := []struct {
+		name     string
+		result   string
+		json     string
+		expected []string
+		check    func(t *testing.T, frame *data.Frame)
+	}{
+		{
+			name: "points",
+			result: `[
+				{"vehicle": "van", "position": {"type": "Point", "coordinates": [-0.1275, 51.5072]}},
+				{"vehicle": "truck", "position": {"type": "Point", "coordinates": [2, 48]}},
+				{"vehicle": "bike", "position": null}
+			]`,
+			json:     `{"rawSql": "SELECT vehicle, position FROM vehicle", "flattenDepth": 1}`,
+			expected: []string{"vehicle", "latitude", "longitude"},
+			check: func(t *testing.T, frame *data.Frame) {
+				latitude, _ := frame.FieldByName("latitude")
+				longitude, _ := frame.FieldByName("longitude")
+				if latitude.Type() != data.FieldTypeNullableFloat64 {
+					t.Fatalf("expected a nullable float latitude, got %s", latitude.Type())
+				}
+				if v, _ := latitude.ConcreteAt(0); v != 51.5072 {
+					t.Errorf("expected the latitude 51.5072, got %v", v)
+				}
+				if v, _ := longitude.ConcreteAt(1); v != 2.0 {
+					t.Errorf("expected the longitude 2, got %v", v)
+				}
+				if _, ok := latitude.ConcreteAt(2); ok {
+					t.Error("expected a null latitude without position")
+				}
+			},
+		},
+		{
+			name: "several points",
+			result: `[
+				{"from": {"type": "Point", "coordinates": [1, 2]}, "to": {"type": "Point", "coordinates": [3, 4]}}
+			]`,
+			json:     `{"rawSql": "SELECT from, to FROM trip"}`,
+			expected: []string{"from.latitude", "from.longitude", "to.latitude", "to.longitude"},
+		},
+		{
+			name: "shapes",
+			result: `[
+				{"zone": "depot", "area": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}},
+				{"zone": "yard", "area": {"type": "Point", "coordinates": [0.5, 0.5]}},
+				{"zone": "routes", "area": {"type": "GeometryCollection", "geometries": [
+					{"type": "LineString", "coordinates": [[0, 0], [1.5, 2]]},
+					{"type": "MultiPoint", "coordinates": [[0, 0], [1, 1]]}
+				]}}
+			]`,
+			json:     `{"rawSql": "SELECT zone, area FROM zone"}`,
+			expected: []string{"zone", "area"},
+			check: func(t *testing.T, frame *data.Frame) {
+				area, _ := frame.FieldByName("area")
+				expected := []string{
+					"POLYGON ((0 0, 1 0, 1 1, 0 0))",
+					"POINT (0.5 0.5)",
+					"GEOMETRYCOLLECTION (LINESTRING (0 0, 1.5 2), MULTIPOINT (0 0, 1 1))",
+				}
+				for i, wkt := range expected {
+					if v, _ := area.ConcreteAt(i); v != wkt {
+						t.Errorf("expected %q in row %d, got %v", wkt, i, v)
+					}
+				}
+			},
+		},
+		{
+			name:     "objects with a type",
+			result:   `[{"event": {"type": "Point", "coordinates": [1, 2], "source": "gps"}}]`,
+			json:     `{"rawSql": "SELECT event FROM log", "flattenDepth": 1}`,
+			expected: []string{"event.type", "event.coordinates", "event.source"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			geometryMock := mocks.MockSurrealDBClient{
+				QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+					return json.RawMessage(`[{"status": "OK", "result": ` + tt.result + `}]`), nil
+				},
+			}
+
+			ds := plugin.NewDatasourceInstance(client.Use(&geometryMock), &config)
+			response := ds.CreateDataResponse(context.TODO(), backend.DataQuery{RefID: "A", JSON: []byte(tt.json)})
+
+			if response.Error != nil {
+				t.Fatalf("unexpected error: %s", response.Error)
+			}
+
+			frame := response.Frames[0]
+			if names := fieldNames(frame); strings.Join(names, ",") != strings.Join(tt.expected, ",") {
+				t.Fatalf("expected fields %v, got %v", tt.expected, names)
+			}
+
+			if tt.check != nil {
+				tt.check(t, frame)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package plugin
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const (
+	// idColumnName is the name of the column holding record IDs.
+	idColumnName = "id"
+	// inColumnName and outColumnName are the names of the columns holding the
+	// records linked by `RELATE` edges.
+	inColumnName  = "in"
+	outColumnName = "out"
+
+	// subtitleColumnName, mainStatColumnName, sourceColumnName and
+	// targetColumnName are the names of the fields of the node graph.
+	subtitleColumnName = "subtitle"
+	mainStatColumnName = "mainstat"
+	sourceColumnName   = "source"
+	targetColumnName   = "target"
+
+	// detailPrefix is the prefix of the fields shown in the details of the
+	// nodes and edges of the node graph.
+	detailPrefix = "detail__"
+)
+
+// graphOptions defines which fields of the nodes of a graph are shown.
+type graphOptions struct {
+	// title is the field shown as the title of nodes, their record ID when empty.
+	title    string
+	subtitle string
+	mainStat string
+}
+
+// graph is the nodes and edges of a graph, in the order they were found.
+type graph struct {
+	nodes rowSet
+	edges rowSet
+	// index is the position of the nodes by record ID.
+	index map[string]int
+}
+
+// toNodeGraph converts the frames of a query into the `nodes` and `edges`
+// frames of the node graph. Rows with `in` and `out` columns, such as the
+// records of `RELATE` edges, are edges between the records of these columns,
+// and other rows with an `id` column are nodes. The properties of the nodes
+// come from the rows of their records and from the `in` and `out` objects of
+// edges, e.g. with `FETCH in, out`; the properties of the edges are the other
+// columns of their rows. Results without edges or nodes give frames without
+// rows, still with the fields of the node graph.
+func toNodeGraph(frames data.Frames, opts graphOptions) data.Frames {
+	g := &graph{index: map[string]int{}}
+
+	for _, frame := range frames {
+		rs := frameRows(frame)
+
+		for _, row := range rs.rows {
+			source, sourceKeys, sourceProperties, sourceOK := recordNode(row[inColumnName])
+			target, targetKeys, targetProperties, targetOK := recordNode(row[outColumnName])
+
+			switch {
+			case sourceOK && targetOK:
+				g.addNode(source, sourceKeys, sourceProperties)
+				g.addNode(target, targetKeys, targetProperties)
+				g.addEdge(rs.columns, row, source, target)
+			case row[inColumnName] == nil && row[outColumnName] == nil:
+				if id, _, _, ok := recordNode(row[idColumnName]); ok {
+					g.addNode(id, rs.columns, row)
+				}
+			}
+		}
+	}
+
+	if len(g.edges.rows) == 0 {
+		g.edges.columns = []string{idColumnName, sourceColumnName, targetColumnName}
+	}
+
+	nodes := graphFrame("nodes", nodeRows(g.nodes, opts))
+	edges := graphFrame("edges", g.edges)
+
+	for _, frame := range []*data.Frame{nodes, edges} {
+		frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeNodeGraph}
+		for _, field := range frame.Fields {
+			if name, ok := strings.CutPrefix(field.Name, detailPrefix); ok {
+				field.Config = &data.FieldConfig{DisplayName: name}
+			}
+		}
+	}
+
+	return data.Frames{nodes, edges}
+}
+
+// graphFrame returns the frame of the nodes or edges of a graph. Without rows,
+// the frame has empty string fields for the columns.
+func graphFrame(name string, rs rowSet) *data.Frame {
+	frame := toDataFrame(rs)
+	frame.Name = name
+
+	if len(rs.rows) == 0 {
+		for _, column := range rs.columns {
+			frame.Fields = append(frame.Fields, data.NewField(column, nil, []string{}))
+		}
+	}
+
+	return frame
+}
+
+// recordNode returns the record ID of a record ID, or of a record object with
+// an `id` along with its keys and properties, and whether the value is either.
+func recordNode(value json.RawMessage) (id string, keys []string, properties map[string]json.RawMessage, ok bool) {
+	v := decodeValue(value)
+
+	if v.kind == kindJSON {
+		var err error
+		if keys, properties, err = unmarshalObject(v.raw); err != nil {
+			return "", nil, nil, false
+		}
+		v = decodeValue(properties[idColumnName])
+	}
+
+	if v.kind != kindString {
+		return "", nil, nil, false
+	}
+
+	return v.value.(string), keys, properties, true
+}
+
+// addNode adds the node of a record with its properties. The properties of a
+// record found again are added to those its node does not have yet.
+func (g *graph) addNode(id string, keys []string, properties map[string]json.RawMessage) {
+	i, ok := g.index[id]
+	if !ok {
+		raw, _ := json.Marshal(id)
+		i = len(g.nodes.rows)
+		g.index[id] = i
+		g.nodes.rows = append(g.nodes.rows, map[string]json.RawMessage{idColumnName: raw})
+		g.nodes.columns = mergeColumns(g.nodes.columns, []string{idColumnName})
+	}
+
+	node := g.nodes.rows[i]
+	for _, key := range keys {
+		if _, ok := node[key]; !ok {
+			if value, ok := properties[key]; ok {
+				node[key] = value
+			}
+		}
+	}
+	g.nodes.columns = mergeColumns(g.nodes.columns, keys)
+}
+
+// addEdge adds the edge of a row between the source and target nodes, with
+// the other columns of the row as details.
+func (g *graph) addEdge(columns []string, row map[string]json.RawMessage, source string, target string) {
+	id := row[idColumnName]
+	if decodeValue(id).kind == kindNull {
+		id, _ = json.Marshal(source + "->" + target)
+	}
+
+	edge := map[string]json.RawMessage{idColumnName: id}
+	edge[sourceColumnName], _ = json.Marshal(source)
+	edge[targetColumnName], _ = json.Marshal(target)
+	keys := []string{idColumnName, sourceColumnName, targetColumnName}
+
+	for _, column := range columns {
+		if column == idColumnName || column == inColumnName || column == outColumnName {
+			continue
+		}
+		if value, ok := row[column]; ok {
+			edge[detailPrefix+column] = value
+			keys = append(keys, detailPrefix+column)
+		}
+	}
+
+	g.edges.rows = append(g.edges.rows, edge)
+	g.edges.columns = mergeColumns(g.edges.columns, keys)
+}
+
+// nodeRows returns the rows of the nodes frame: the `id`, `title`, `subtitle`
+// and `mainstat` of the nodes, followed by their other properties as details.
+func nodeRows(nodes rowSet, opts graphOptions) rowSet {
+	columns := []string{idColumnName, titleColumnName}
+	if opts.subtitle != "" {
+		columns = append(columns, subtitleColumnName)
+	}
+	if opts.mainStat != "" {
+		columns = append(columns, mainStatColumnName)
+	}
+
+	used := []string{idColumnName, opts.title, opts.subtitle, opts.mainStat}
+	for _, column := range nodes.columns {
+		if !slices.Contains(used, column) {
+			columns = append(columns, detailPrefix+column)
+		}
+	}
+
+	rows := make([]map[string]json.RawMessage, len(nodes.rows))
+	for i, node := range nodes.rows {
+		row := map[string]json.RawMessage{idColumnName: node[idColumnName], titleColumnName: node[idColumnName]}
+
+		if title, ok := node[opts.title]; ok && opts.title != "" {
+			row[titleColumnName] = title
+		}
+		if opts.subtitle != "" {
+			row[subtitleColumnName] = node[opts.subtitle]
+		}
+		if opts.mainStat != "" {
+			row[mainStatColumnName] = node[opts.mainStat]
+		}
+		for column, value := range node {
+			if !slices.Contains(used, column) {
+				row[detailPrefix+column] = value
+			}
+		}
+
+		rows[i] = row
+	}
+
+	return rowSet{columns: columns, rows: rows}
+}
+
+// frameRows returns the rows of a frame, with the values of the fields as JSON.
+// Null values are left out of the rows.
+func frameRows(frame *data.Frame) rowSet {
+	rs := rowSet{rows: make([]map[string]json.RawMessage, frame.Rows())}
+
+	for _, field := range frame.Fields {
+		rs.columns = append(rs.columns, field.Name)
+	}
+
+	for i := range rs.rows {
+		row := map[string]json.RawMessage{}
+		for _, field := range frame.Fields {
+			v, ok := field.ConcreteAt(i)
+			if !ok {
+				continue
+			}
+			if raw, err := json.Marshal(v); err == nil {
+				row[field.Name] = raw
+			}
+		}
+		rs.rows[i] = row
+	}
+
+	return rs
+}
@@ -0,0 +1,156 @@
+package plugin_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/grafana-labs/surrealdb-datasource/internal/mocks"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/plugin"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// serviceMock returns services and the edges of their dependencies, one of
+// them to a service which is not a record of the first statement.
+var serviceMock = mocks.MockSurrealDBClient{
+	QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+		return json.RawMessage(`[
+			{"status": "OK", "result": [
+				{"id": "service:api", "name": "API", "team": "core", "rps": 120},
+				{"id": "service:db", "name": "Database", "team": "data", "rps": 80}
+			]},
+			{"status": "OK", "result": [
+				{"id": "depends_on:1", "in": "service:api", "out": "service:db", "latency": 12},
+				{"id": "depends_on:2", "in": "service:api", "out": "service:cache", "latency": 2}
+			]}
+		]`), nil
+	},
+}
+
+func TestCreateDataResponse_Graph(t *testing.T) {
+	ds := plugin.NewDatasourceInstance(client.Use(&serviceMock), &config)
+	response := ds.CreateDataResponse(context.TODO(), backend.DataQuery{
+		RefID: "A",
+		JSON: []byte(`{
+			"rawSql": "SELECT * FROM service; SELECT * FROM depends_on",
+			"format": "graph",
+			"nodeTitle": "name",
+			"nodeSubtitle": "team",
+			"nodeMainStat": "rps"
+		}`),
+	})
+
+	if response.Error != nil {
+		t.Fatalf("unexpected error: %s", response.Error)
+	}
+	if len(response.Frames) != 2 {
+		t.Fatalf("expected the nodes and edges frames, got %d frames", len(response.Frames))
+	}
+
+	nodes, edges := response.Frames[0], response.Frames[1]
+	for _, frame := range response.Frames {
+		if frame.Meta == nil || frame.Meta.PreferredVisualization != data.VisTypeNodeGraph {
+			t.Errorf("expected frame %s to prefer the node graph, got %v", frame.Name, frame.Meta)
+		}
+	}
+
+	if nodes.Name != "nodes" || strings.Join(fieldNames(nodes), ",") != "id,title,subtitle,mainstat" {
+		t.Fatalf("unexpected nodes frame %s with fields %v", nodes.Name, fieldNames(nodes))
+	}
+	if rows := nodes.Rows(); rows != 3 {
+		t.Fatalf("expected 3 nodes, got %d", rows)
+	}
+
+	expectedNodes := [][4]interface{}{
+		{"service:api", "API", "core", int64(120)},
+		{"service:db", "Database", "data", int64(80)},
+		{"service:cache", "service:cache", nil, nil},
+	}
+	for i, expected := range expectedNodes {
+		for j, field := range nodes.Fields {
+			v, ok := field.ConcreteAt(i)
+			if !ok {
+				v = nil
+			}
+			if v != expected[j] {
+				t.Errorf("expected %s of node %d to be %v, got %v", field.Name, i, expected[j], v)
+			}
+		}
+	}
+
+	expectedEdgeFields := "id,source,target,detail__latency"
+	if edges.Name != "edges" || strings.Join(fieldNames(edges), ",") != expectedEdgeFields {
+		t.Fatalf("unexpected edges frame %s with fields %v", edges.Name, fieldNames(edges))
+	}
+	if name := edges.Fields[3].Config.DisplayName; name != "latency" {
+		t.Errorf("expected the latency detail to be displayed as latency, got %q", name)
+	}
+
+	target, _ := edges.Fields[2].ConcreteAt(1)
+	if target != "service:cache" {
+		t.Errorf("expected the second edge to target service:cache, got %v", target)
+	}
+}
+
+func TestCreateDataResponse_GraphFetched(t *testing.T) {
+	fetchedMock := mocks.MockSurrealDBClient{
+		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+			return json.RawMessage(`[{"status": "OK", "result": [
+				{"id": "depends_on:1", "in": {"id": "service:api", "name": "API"}, "out": {"id": "service:db", "name": "Database"}}
+			]}]`), nil
+		},
+	}
+
+	ds := plugin.NewDatasourceInstance(client.Use(&fetchedMock), &config)
+	response := ds.CreateDataResponse(context.TODO(), backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"rawSql": "SELECT * FROM depends_on FETCH in, out", "format": "graph", "flattenDepth": 1}`),
+	})
+
+	if response.Error != nil {
+		t.Fatalf("unexpected error: %s", response.Error)
+	}
+
+	nodes := response.Frames[0]
+	if names := strings.Join(fieldNames(nodes), ","); names != "id,title,detail__name" {
+		t.Fatalf("expected the fields id,title,detail__name, got %s", names)
+	}
+
+	names, _ := nodes.FieldByName("detail__name")
+	for i, name := range []string{"API", "Database"} {
+		if v, _ := names.ConcreteAt(i); v != name {
+			t.Errorf("expected node %d to be named %s, got %v", i, name, v)
+		}
+	}
+}
+
+func TestCreateDataResponse_GraphWithoutRecords(t *testing.T) {
+	ds := plugin.NewDatasourceInstance(client.Use(&personMock), &config)
+	response := ds.CreateDataResponse(context.TODO(), backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"rawSql": "SELECT name FROM person", "format": "graph", "nodeMainStat": "age"}`),
+	})
+
+	if response.Error != nil {
+		t.Fatalf("unexpected error: %s", response.Error)
+	}
+	if len(response.Frames) != 2 {
+		t.Fatalf("expected the nodes and edges frames, got %d frames", len(response.Frames))
+	}
+
+	expected := map[string]string{"nodes": "id,title,mainstat", "edges": "id,source,target"}
+	for _, frame := range response.Frames {
+		if names := strings.Join(fieldNames(frame), ","); names != expected[frame.Name] {
+			t.Errorf("expected the %s fields %s, got %s", frame.Name, expected[frame.Name], names)
+		}
+		if rows := frame.Rows(); rows != 0 {
+			t.Errorf("expected no %s, got %d", frame.Name, rows)
+		}
+		if frame.Meta == nil || frame.Meta.PreferredVisualization != data.VisTypeNodeGraph {
+			t.Errorf("expected frame %s to prefer the node graph, got %v", frame.Name, frame.Meta)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/gtime"
+	"github.com/grafana/grafana-plugin-sdk-go/data/sqlutil"
+)
+
+// groupUnits are the units accepted by the SurrealQL `time::group` function.
+var groupUnits = map[string]bool{
+	"year":   true,
+	"month":  true,
+	"day":    true,
+	"hour":   true,
+	"minute": true,
+	"second": true,
+}
+
+// macros is the set of SurrealQL macros used in place of sqlutil.DefaultMacros,
+// which produce SQL syntax that SurrealDB does not accept.
+var macros = sqlutil.Macros{
+	"interval":        macroInterval,
+	"interval_ms":     macroIntervalMS,
+	"timeFilter":      macroTimeFilter,
+	"timeFrom":        macroTimeFrom,
+	"timeGroup":       macroTimeGroup,
+	"timeTo":          macroTimeTo,
+	"unixEpochFilter": macroUnixEpochFilter,
+	"unixEpochFrom":   macroUnixEpochFrom,
+	"unixEpochTo":     macroUnixEpochTo,
+}
+
+// macroRegex matches the references to the macros, e.g. `$__timeFilter`.
+var macroRegex = macroPattern(macros)
+
+// macroPattern returns the regular expression matching the names of macros,
+// longest first so that `$__interval_ms` is not matched as `$__interval`.
+func macroPattern(macros sqlutil.Macros) *regexp.Regexp {
+	names := make([]string, 0, len(macros))
+	for name := range macros {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return len(names[i]) > len(names[j])
+	})
+
+	return regexp.MustCompile(`\$__(` + strings.Join(names, "|") + `)\b`)
+}
+
+// interpolateMacros expands the macros of a query. Unlike sqlutil.Interpolate,
+// it only expands our macros, leaving the other sqlutil.DefaultMacros such as
+// `$__column` untouched, and expands the query in a single pass, so that the
+// expansion of a macro is never expanded again.
+func interpolateMacros(query *sqlutil.Query) (string, error) {
+	var sb strings.Builder
+
+	sql := query.RawSQL
+	for {
+		loc := macroRegex.FindStringSubmatchIndex(sql)
+		if loc == nil {
+			sb.WriteString(sql)
+			return sb.String(), nil
+		}
+
+		name := sql[loc[2]:loc[3]]
+		args, length := parseMacroArgs(sql[loc[1]:])
+		if length < 0 {
+			return "", fmt.Errorf("failed to parse the arguments of $__%s (missing close bracket?)", name)
+		}
+
+		res, err := macros[name](query, args)
+		if err != nil {
+			return "", err
+		}
+
+		sb.WriteString(sql[:loc[0]])
+		sb.WriteString(res)
+		sql = sql[loc[1]+length:]
+	}
+}
+
+// parseMacroArgs returns the trimmed arguments of the argument list in brackets
+// at the start of s, if any, and the length of the list, or -1 when it is not
+// closed. Arguments are separated by the commas outside of nested brackets, and
+// empty brackets are a single empty argument, as with sqlutil.Interpolate.
+func parseMacroArgs(s string) ([]string, int) {
+	if !strings.HasPrefix(s, "(") {
+		return nil, 0
+	}
+
+	var args []string
+	depth, start := 0, 1
+
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return append(args, strings.TrimSpace(s[start:i])), i + 1
+			}
+		case ',':
+			if depth == 1 {
+				args = append(args, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+
+	return nil, -1
+}
+
+// macroInterval returns the query interval as a SurrealQL duration.
+//
+//	$__interval => 5m
+func macroInterval(query *sqlutil.Query, _ []string) (string, error) {
+	return formatDuration(query.Interval), nil
+}
+
+// macroIntervalMS returns the query interval in milliseconds.
+//
+//	$__interval_ms => 300000
+func macroIntervalMS(query *sqlutil.Query, _ []string) (string, error) {
+	return strconv.FormatInt(query.Interval.Milliseconds(), 10), nil
+}
+
+// macroTimeFilter filters a datetime column by the query time range.
+//
+//	$__timeFilter(time) => time >= d'2023-11-27T22:30:23Z' AND time <= d'2023-11-28T22:30:23Z'
+func macroTimeFilter(query *sqlutil.Query, args []string) (string, error) {
+	if len(args) != 1 || args[0] == "" {
+		return "", fmt.Errorf("%w: expected 1 argument, received %d", sqlutil.ErrorBadArgumentCount, len(args))
+	}
+
+	return fmt.Sprintf("%s >= %s AND %s <= %s", args[0], formatDatetime(query.TimeRange.From), args[0], formatDatetime(query.TimeRange.To)), nil
+}
+
+// macroTimeFrom returns the start of the query time range as a datetime, or a
+// filter on a datetime column when a column is given.
+//
+//	$__timeFrom => d'2023-11-27T22:30:23Z'
+//	$__timeFrom(time) => time >= d'2023-11-27T22:30:23Z'
+func macroTimeFrom(query *sqlutil.Query, args []string) (string, error) {
+	return datetimeOrFilter(query.TimeRange.From, ">=", args)
+}
+
+// macroTimeTo returns the end of the query time range as a datetime, or a
+// filter on a datetime column when a column is given.
+//
+//	$__timeTo => d'2023-11-28T22:30:23Z'
+//	$__timeTo(time) => time <= d'2023-11-28T22:30:23Z'
+func macroTimeTo(query *sqlutil.Query, args []string) (string, error) {
+	return datetimeOrFilter(query.TimeRange.To, "<=", args)
+}
+
+// macroTimeGroup groups a datetime column into buckets. Calendar units use
+// `time::group`, any other interval uses `time::floor`.
+//
+//	$__timeGroup(time, minute) => time::group(time, 'minute')
+//	$__timeGroup(time, 5m) => time::floor(time, 5m)
+//	$__timeGroup(time, $__interval) => time::floor(time, 1m)
+func macroTimeGroup(query *sqlutil.Query, args []string) (string, error) {
+	if len(args) != 2 {
+		return "", fmt.Errorf("%w: expected 2 arguments, received %d", sqlutil.ErrorBadArgumentCount, len(args))
+	}
+
+	column, interval := args[0], strings.Trim(args[1], `'"`)
+
+	if groupUnits[interval] {
+		return fmt.Sprintf("time::group(%s, '%s')", column, interval), nil
+	}
+
+	d := query.Interval
+	if interval != "$__interval" {
+		var err error
+		if d, err = gtime.ParseInterval(interval); err != nil {
+			return "", fmt.Errorf("invalid interval %q: %w", interval, err)
+		}
+	}
+
+	if d <= 0 {
+		return "", fmt.Errorf("invalid interval %q: interval must be positive", interval)
+	}
+
+	return fmt.Sprintf("time::floor(%s, %s)", column, formatDuration(d)), nil
+}
+
+// macroUnixEpochFilter filters a column holding seconds since the unix epoch
+// by the query time range.
+//
+//	$__unixEpochFilter(ts) => ts >= 1701124223 AND ts <= 1701210623
+func macroUnixEpochFilter(query *sqlutil.Query, args []string) (string, error) {
+	if len(args) != 1 || args[0] == "" {
+		return "", fmt.Errorf("%w: expected 1 argument, received %d", sqlutil.ErrorBadArgumentCount, len(args))
+	}
+
+	return fmt.Sprintf("%s >= %d AND %s <= %d", args[0], query.TimeRange.From.Unix(), args[0], query.TimeRange.To.Unix()), nil
+}
+
+// macroUnixEpochFrom returns the start of the query time range in seconds since the unix epoch.
+//
+//	$__unixEpochFrom() => 1701124223
+func macroUnixEpochFrom(query *sqlutil.Query, _ []string) (string, error) {
+	return strconv.FormatInt(query.TimeRange.From.Unix(), 10), nil
+}
+
+// macroUnixEpochTo returns the end of the query time range in seconds since the unix epoch.
+//
+//	$__unixEpochTo() => 1701210623
+func macroUnixEpochTo(query *sqlutil.Query, _ []string) (string, error) {
+	return strconv.FormatInt(query.TimeRange.To.Unix(), 10), nil
+}
+
+// datetimeOrFilter returns t as a datetime, or a filter comparing a column to t
+// when a column is given in args.
+func datetimeOrFilter(t time.Time, op string, args []string) (string, error) {
+	switch {
+	case len(args) == 0 || (len(args) == 1 && args[0] == ""):
+		return formatDatetime(t), nil
+	case len(args) == 1:
+		return fmt.Sprintf("%s %s %s", args[0], op, formatDatetime(t)), nil
+	}
+
+	return "", fmt.Errorf("%w: expected at most 1 argument, received %d", sqlutil.ErrorBadArgumentCount, len(args))
+}
+
+// formatDatetime formats t as a SurrealQL datetime literal.
+func formatDatetime(t time.Time) string {
+	return fmt.Sprintf("d'%s'", t.UTC().Format(time.RFC3339Nano))
+}
+
+// durationUnits are the SurrealQL duration units from largest to smallest.
+var durationUnits = []struct {
+	suffix string
+	unit   time.Duration
+}{
+	{"w", 7 * 24 * time.Hour},
+	{"d", 24 * time.Hour},
+	{"h", time.Hour},
+	{"m", time.Minute},
+	{"s", time.Second},
+	{"ms", time.Millisecond},
+	{"us", time.Microsecond},
+	{"ns", time.Nanosecond},
+}
+
+// formatDuration formats d as a SurrealQL duration literal, e.g. `1h30m`.
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return "0s"
+	}
+
+	var sb strings.Builder
+
+	for _, u := range durationUnits {
+		if n := d / u.unit; n > 0 {
+			sb.WriteString(strconv.FormatInt(int64(n), 10))
+			sb.WriteString(u.suffix)
+			d -= n * u.unit
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,160 @@
+package plugin_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana-labs/surrealdb-datasource/internal/mocks"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/plugin"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestMacros(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		expected string
+		err      bool
+	}{
+		{
+			name:     "timeFilter",
+			input:    "SELECT * FROM metrics WHERE $__timeFilter(time)",
+			expected: "SELECT * FROM metrics WHERE time >= d'2023-11-27T22:30:23Z' AND time <= d'2023-11-28T22:30:23.5Z'",
+		},
+		{
+			name:     "timeFilter with nested field",
+			input:    "SELECT * FROM person WHERE $__timeFilter(time.created_at)",
+			expected: "SELECT * FROM person WHERE time.created_at >= d'2023-11-27T22:30:23Z' AND time.created_at <= d'2023-11-28T22:30:23.5Z'",
+		},
+		{
+			name:  "timeFilter without column",
+			input: "SELECT * FROM metrics WHERE $__timeFilter()",
+			err:   true,
+		},
+		{
+			name:     "timeFrom and timeTo",
+			input:    "SELECT * FROM metrics WHERE time >= $__timeFrom AND time <= $__timeTo()",
+			expected: "SELECT * FROM metrics WHERE time >= d'2023-11-27T22:30:23Z' AND time <= d'2023-11-28T22:30:23.5Z'",
+		},
+		{
+			name:     "timeFrom with column",
+			input:    "SELECT * FROM metrics WHERE $__timeFrom(time)",
+			expected: "SELECT * FROM metrics WHERE time >= d'2023-11-27T22:30:23Z'",
+		},
+		{
+			name:     "timeTo with column",
+			input:    "SELECT * FROM metrics WHERE $__timeTo(time)",
+			expected: "SELECT * FROM metrics WHERE time <= d'2023-11-28T22:30:23.5Z'",
+		},
+		{
+			name:     "timeGroup with calendar unit",
+			input:    "SELECT $__timeGroup(ts, minute) AS time FROM metrics GROUP BY time",
+			expected: "SELECT time::group(ts, 'minute') AS time FROM metrics GROUP BY time",
+		},
+		{
+			name:     "timeGroup with quoted calendar unit",
+			input:    "SELECT $__timeGroup(ts, 'hour') AS time FROM metrics GROUP BY time",
+			expected: "SELECT time::group(ts, 'hour') AS time FROM metrics GROUP BY time",
+		},
+		{
+			name:     "timeGroup with duration",
+			input:    "SELECT $__timeGroup(ts, 90m) AS time FROM metrics GROUP BY time",
+			expected: "SELECT time::floor(ts, 1h30m) AS time FROM metrics GROUP BY time",
+		},
+		{
+			name:     "timeGroup with interval",
+			input:    "SELECT $__timeGroup(ts, $__interval) AS time FROM metrics GROUP BY time",
+			expected: "SELECT time::floor(ts, 5m) AS time FROM metrics GROUP BY time",
+		},
+		{
+			name:  "timeGroup with invalid interval",
+			input: "SELECT $__timeGroup(ts, fortnight) AS time FROM metrics GROUP BY time",
+			err:   true,
+		},
+		{
+			name:  "timeGroup without interval",
+			input: "SELECT $__timeGroup(ts) AS time FROM metrics GROUP BY time",
+			err:   true,
+		},
+		{
+			name:     "interval",
+			input:    "SELECT * FROM metrics WHERE time > time::now() - $__interval",
+			expected: "SELECT * FROM metrics WHERE time > time::now() - 5m",
+		},
+		{
+			name:     "interval_ms",
+			input:    "SELECT $__interval_ms AS interval FROM metrics",
+			expected: "SELECT 300000 AS interval FROM metrics",
+		},
+		{
+			name:     "unixEpochFilter",
+			input:    "SELECT * FROM metrics WHERE $__unixEpochFilter(ts)",
+			expected: "SELECT * FROM metrics WHERE ts >= 1701124223 AND ts <= 1701210623",
+		},
+		{
+			name:     "macros of other datasources",
+			input:    "SELECT $__column FROM $__table WHERE $__timeFilter(time)",
+			expected: "SELECT $__column FROM $__table WHERE time >= d'2023-11-27T22:30:23Z' AND time <= d'2023-11-28T22:30:23.5Z'",
+		},
+		{
+			name:     "nested brackets",
+			input:    "SELECT * FROM metrics WHERE $__timeFilter(time::round(ts, 1s))",
+			expected: "SELECT * FROM metrics WHERE time::round(ts, 1s) >= d'2023-11-27T22:30:23Z' AND time::round(ts, 1s) <= d'2023-11-28T22:30:23.5Z'",
+		},
+		{
+			name:  "unclosed brackets",
+			input: "SELECT * FROM metrics WHERE $__timeFilter(time",
+			err:   true,
+		},
+		{
+			name:     "unixEpochFrom and unixEpochTo",
+			input:    "SELECT * FROM metrics WHERE ts >= $__unixEpochFrom() AND ts <= $__unixEpochTo()",
+			expected: "SELECT * FROM metrics WHERE ts >= 1701124223 AND ts <= 1701210623",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var sql string
+
+			mock := mocks.MockSurrealDBClient{
+				QueryFunc: func(s string, vars interface{}) (interface{}, error) {
+					sql = s
+					return []interface{}{
+						map[string]interface{}{"status": "OK", "result": []interface{}{}, "time": "1ms"},
+					}, nil
+				},
+			}
+
+			ds := plugin.NewDatasourceInstance(client.Use(&mock), &config)
+
+			query := backend.DataQuery{
+				RefID:    "A",
+				JSON:     []byte(`{"rawSql": "` + tt.input + `"}`),
+				Interval: 5 * time.Minute,
+				TimeRange: backend.TimeRange{
+					From: time.Date(2023, 11, 27, 22, 30, 23, 0, time.UTC),
+					To:   time.Date(2023, 11, 28, 22, 30, 23, 500000000, time.UTC),
+				},
+			}
+
+			response := ds.CreateDataResponse(context.TODO(), query)
+
+			if tt.err {
+				if response.Error == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+
+			if response.Error != nil {
+				t.Fatalf("unexpected error: %s", response.Error)
+			}
+			if sql != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, sql)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// QueryFormat defines how the results of a query are shaped into data frames.
+type QueryFormat string
+
+const (
+	// FormatTable returns the results as they were returned by the database.
+	FormatTable QueryFormat = "table"
+	// FormatTimeSeries sorts the results by time and converts long frames to wide frames.
+	FormatTimeSeries QueryFormat = "time_series"
+	// FormatGraph converts edge records into the nodes and edges of the node graph.
+	FormatGraph QueryFormat = "graph"
+)
+
+// ArrayMode defines how the arrays of the results of a query are represented in data frames.
+type ArrayMode string
+
+const (
+	// ArrayModeJSON keeps arrays as JSON values.
+	ArrayModeJSON ArrayMode = "json"
+	// ArrayModeJoin joins the elements of arrays into comma-separated strings.
+	ArrayModeJoin ArrayMode = "join"
+	// ArrayModeExplode turns each element of arrays into its own row.
+	ArrayModeExplode ArrayMode = "explode"
+)
+
+const (
+	// QueryTypeVariable is the query type of the queries of dashboard variables.
+	QueryTypeVariable = "variable"
+	// QueryTypeAnnotation is the query type of the queries of annotations.
+	QueryTypeAnnotation = "annotation"
+)
+
+// SurrealQuery is the query model sent by the query editor.
+type SurrealQuery struct {
+	RawSQL string      `json:"rawSql"`
+	Format QueryFormat `json:"format,omitempty"`
+	// Live streams the changes to the records selected by the query using a `LIVE SELECT`.
+	Live bool `json:"live,omitempty"`
+	// Variables are the dashboard variables referenced by the query, by name.
+	Variables map[string]TemplateVariable `json:"variables,omitempty"`
+	// AdhocFilters are the ad-hoc filters of the dashboard, added to the WHERE
+	// clause of the SELECT statements of the query.
+	AdhocFilters []AdhocFilter `json:"adhocFilters,omitempty"`
+	// QueryTimeout and MaxRows override the settings of the datasource when
+	// they are not zero.
+	QueryTimeout int `json:"queryTimeout,omitempty"`
+	MaxRows      int `json:"maxRows,omitempty"`
+	// FlattenDepth is the number of levels of nested objects flattened into
+	// dotted columns, e.g. `address.city`, none when zero.
+	FlattenDepth int `json:"flattenDepth,omitempty"`
+	// ArrayMode is how arrays are represented, ArrayModeJSON when empty.
+	ArrayMode ArrayMode `json:"arrayMode,omitempty"`
+	// SplitRecordIDs adds the table and the id of the record IDs of a column
+	// as the `<column>.tb` and `<column>.id` columns.
+	SplitRecordIDs bool `json:"splitRecordIds,omitempty"`
+	// KeepEmptyResults returns an empty frame for the statements without rows,
+	// such as `LET` statements, so that frames match the statements one to one.
+	KeepEmptyResults bool `json:"keepEmptyResults,omitempty"`
+	// NodeTitle, NodeSubtitle and NodeMainStat are the fields of the records
+	// shown as the title, subtitle and main stat of the nodes of the graph
+	// format. Nodes are titled with their record ID when NodeTitle is empty.
+	NodeTitle    string `json:"nodeTitle,omitempty"`
+	NodeSubtitle string `json:"nodeSubtitle,omitempty"`
+	NodeMainStat string `json:"nodeMainStat,omitempty"`
+}
+
+// getQuery unmarshals the query model from a data query.
+func getQuery(query backend.DataQuery) (*SurrealQuery, error) {
+	model := &SurrealQuery{}
+
+	if err := json.Unmarshal(query.JSON, model); err != nil {
+		return nil, fmt.Errorf("error unmarshaling query JSON to the query model: %w", err)
+	}
+
+	if model.Format == "" {
+		model.Format = FormatTable
+	}
+
+	if model.ArrayMode == "" {
+		model.ArrayMode = ArrayModeJSON
+	}
+
+	return model, nil
+}
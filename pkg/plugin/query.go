@@ -1,103 +1,482 @@
 package plugin
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"sort"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/surrealql"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana-plugin-sdk-go/data/sqlutil"
 	"github.com/surrealdb/surrealdb.go"
 )
 
-// createDataResponse creates a data response from a data query.
+// typedParamRegex matches the references to the parameters bound with their
+// types by typedParams, but not `$interval_ms`.
+var typedParamRegex = regexp.MustCompile(`\$(from|to|interval)\b`)
+
+const (
+	// statusOK is the status of a statement that completed successfully.
+	statusOK = "OK"
+	// valueColumnName is the name of the column holding values which are not objects.
+	valueColumnName = "value"
+)
+
+// CreateDataResponse creates a data response from a data query, run with the
+// credentials of the datasource.
 func (d *SurrealDatasource) CreateDataResponse(ctx context.Context, query backend.DataQuery) backend.DataResponse {
-	str, err := sqlStringFromDataQuery(query)
+	return d.createDataResponse(ctx, d.client, query)
+}
+
+// createDataResponse creates a data response from a data query run with c.
+func (d *SurrealDatasource) createDataResponse(ctx context.Context, c *client.Client, query backend.DataQuery) backend.DataResponse {
+	model, err := getQuery(query)
+	if err != nil {
+		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("query: %v", err.Error()))
+	}
+
+	str, err := sqlStringFromDataQuery(query, model)
 	if err != nil {
 		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("sql: %v", err.Error()))
 	}
 
-	result, err := d.client.QueryWithContext(ctx, str, nil)
+	if model.Live {
+		if d.sessions != nil {
+			return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, "live: live queries cannot run as the Grafana user")
+		}
+		response, err := d.liveResponse(ctx, str, queryVars(query))
+		if err != nil {
+			return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("live: %v", err.Error()))
+		}
+		return response
+	}
+
+	timeout, maxRows := d.queryLimits(model)
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if maxRows > 0 {
+		// one more row than allowed tells whether the result was truncated
+		str = surrealql.WithLimit(str, maxRows+1)
+	}
+
+	// the parameters are bound as JSON strings, so those which are not strings
+	// in SurrealQL are bound again with their types by LET statements
+	lets := typedParams(str, query)
+	if len(lets) > 0 {
+		str = strings.Join(append(lets, str), ";\n")
+	}
+
+	result, err := c.QueryWithContext(ctx, str, queryVars(query))
 	if err != nil {
+		switch {
+		case timeout > 0 && errors.Is(err, context.DeadlineExceeded):
+			return backend.ErrDataResponseWithSource(backend.StatusTimeout, backend.ErrorSourceDownstream, fmt.Sprintf("query: timed out after %s", timeout))
+		case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, client.ErrTimeout):
+			return backend.ErrDataResponseWithSource(backend.StatusTimeout, backend.ErrorSourceDownstream, fmt.Sprintf("query: %v", err))
+		case errors.Is(err, context.Canceled):
+			return backend.ErrDataResponseWithSource(statusCanceled, backend.ErrorSourceDownstream, fmt.Sprintf("query: %v", err))
+		}
 		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourceDownstream, fmt.Sprintf("query: %v", err.Error()))
 	}
 
-	response, err := buildResponse(result)
+	opts := frameOptions{
+		maxRows:        maxRows,
+		flattenDepth:   model.FlattenDepth,
+		arrayMode:      model.ArrayMode,
+		splitRecordIDs: model.SplitRecordIDs,
+		keepEmpty:      model.KeepEmptyResults,
+		datasource:     datasourceFromContext(ctx),
+		skipStatements: len(lets),
+	}
+	if model.Format == FormatGraph {
+		// the nodes of edges are read from their `in` and `out` objects
+		opts.flattenDepth, opts.arrayMode, opts.splitRecordIDs = 0, ArrayModeJSON, false
+	}
+
+	response, err := buildResponse(result, opts)
 	if err != nil {
 		return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("response: %v", err.Error()))
 	}
 
+	if query.QueryType == QueryTypeVariable {
+		frame, err := toVariableFrame(response.Frames)
+		if err != nil {
+			return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("variable: %v", err.Error()))
+		}
+		return backend.DataResponse{Frames: data.Frames{frame}}
+	}
+
+	if query.QueryType == QueryTypeAnnotation {
+		for i, frame := range response.Frames {
+			if response.Frames[i], err = toAnnotationFrame(frame); err != nil {
+				return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("annotation: %v", err.Error()))
+			}
+		}
+		return response
+	}
+
+	if model.Format == FormatGraph {
+		response.Frames = toNodeGraph(response.Frames, graphOptions{
+			title:    model.NodeTitle,
+			subtitle: model.NodeSubtitle,
+			mainStat: model.NodeMainStat,
+		})
+	}
+
+	if model.Format == FormatTimeSeries {
+		for i, frame := range response.Frames {
+			if response.Frames[i], err = toTimeSeries(frame); err != nil {
+				return backend.ErrDataResponseWithSource(backend.StatusBadRequest, backend.ErrorSourcePlugin, fmt.Sprintf("time series: %v", err.Error()))
+			}
+		}
+	}
+
 	return response
 }
 
-// sqlStringFromDataQuery converts a data query into a SQL string, interpolating any macros.
-func sqlStringFromDataQuery(query backend.DataQuery) (string, error) {
-	sq, err := sqlutil.GetQuery(query)
-	if err != nil {
-		return "", err
+// queryLimits returns the timeout and the maximum number of rows per statement
+// of a query, from the datasource settings, lowered by the query if it sets
+// lower limits: a query can never lift the limits of the datasource. Zero means
+// unlimited.
+func (d *SurrealDatasource) queryLimits(model *SurrealQuery) (time.Duration, int) {
+	timeout := lowerLimit(d.config.QueryTimeout, model.QueryTimeout)
+	maxRows := lowerLimit(d.config.MaxRows, model.MaxRows)
+
+	return time.Duration(timeout) * time.Second, maxRows
+}
+
+// lowerLimit returns the lowest of two limits, where zero means unlimited.
+func lowerLimit(a int, b int) int {
+	if a <= 0 || (b > 0 && b < a) {
+		return b
+	}
+
+	return a
+}
+
+// sqlStringFromDataQuery converts a data query into a SQL string, interpolating
+// any template variables and macros and applying the ad-hoc filters. Macros are
+// expanded first and the ad-hoc filters applied last, so that macros and
+// variables in the values of variables and filters are never replaced.
+func sqlStringFromDataQuery(query backend.DataQuery, model *SurrealQuery) (string, error) {
+	sq := &sqlutil.Query{
+		RawSQL:        model.RawSQL,
+		RefID:         query.RefID,
+		Interval:      query.Interval,
+		TimeRange:     query.TimeRange,
+		MaxDataPoints: query.MaxDataPoints,
 	}
 
 	// apply grafana macros to the query
-	str, err := sqlutil.Interpolate(sq, sqlutil.DefaultMacros)
+	str, err := interpolateMacros(sq)
 	if err != nil {
 		return "", err
 	}
 
-	return str, nil
+	return applyAdhocFilters(interpolateVariables(str, model.Variables), model.AdhocFilters)
+}
+
+// datasourceFromContext returns the datasource of the plugin context of ctx.
+func datasourceFromContext(ctx context.Context) datasourceRef {
+	settings := backend.PluginConfigFromContext(ctx).DataSourceInstanceSettings
+	if settings == nil {
+		return datasourceRef{}
+	}
+
+	return datasourceRef{uid: settings.UID, name: settings.Name}
+}
+
+// queryVars returns the parameters bound to every query, which lets queries
+// reference the dashboard time range and interval without macro expansion, e.g.
+// `SELECT * FROM metrics WHERE time >= $from AND time <= $to`.
+func queryVars(query backend.DataQuery) map[string]interface{} {
+	return map[string]interface{}{
+		"from":            query.TimeRange.From.UTC().Format(time.RFC3339Nano),
+		"to":              query.TimeRange.To.UTC().Format(time.RFC3339Nano),
+		"interval":        formatDuration(query.Interval),
+		"interval_ms":     query.Interval.Milliseconds(),
+		"max_data_points": query.MaxDataPoints,
+		"ref_id":          query.RefID,
+	}
+}
+
+// typedParams returns the LET statements binding the `$from` and `$to`
+// datetimes and the `$interval` duration referenced by a query, which would
+// otherwise be strings.
+//
+//	SELECT * FROM metrics WHERE time >= $from => LET $from = d'2023-11-27T22:30:23Z'
+func typedParams(sql string, query backend.DataQuery) []string {
+	values := map[string]string{
+		"from":     formatDatetime(query.TimeRange.From),
+		"to":       formatDatetime(query.TimeRange.To),
+		"interval": formatDuration(query.Interval),
+	}
+
+	var lets []string
+	seen := map[string]bool{}
+
+	for _, match := range typedParamRegex.FindAllStringSubmatch(sql, -1) {
+		if name := match[1]; !seen[name] {
+			seen[name] = true
+			lets = append(lets, fmt.Sprintf("LET $%s = %s", name, values[name]))
+		}
+	}
+
+	return lets
 }
 
 // buildResponse converts the response from the database into a data response.
-func buildResponse(result interface{}) (backend.DataResponse, error) {
+// A query can contain several statements, and SurrealDB returns one result per
+// statement; each result becomes its own frame. Statements without rows, such
+// as `LET`, are skipped unless the options keep them as empty frames, and
+// failed statements are reported as frame notices unless every statement
+// failed. The rows are shaped according to the options, then truncated with a
+// frame notice when there are more rows than allowed by the options. Columns of
+// geometries are converted for the Geomap panel, and columns of record IDs get
+// a data link to explore the records.
+func buildResponse(result interface{}, opts frameOptions) (backend.DataResponse, error) {
 	var response backend.DataResponse
 
-	// unmarshal the response into a slice of maps.
-	// each map represents a row in the response from the database
-	// as a map of column name to value. The value is a `json.RawMessage`.
-	var res []map[string]json.RawMessage
-	ok, err := surrealdb.UnmarshalRaw(result, &res)
+	statements, err := unmarshalStatements(result)
 	if err != nil {
 		return response, err
 	}
 
-	if !ok {
-		return response, nil
-	}
+	// the results of the statements added to the query are left out
+	statements = statements[min(opts.skipStatements, len(statements)):]
+
+	var failed []string
+
+	for _, statement := range statements {
+		if statement.Status != statusOK {
+			msg := statementError(statement)
+			failed = append(failed, msg)
+
+			frame := data.NewFrame("response")
+			frame.AppendNotices(data.Notice{Severity: data.NoticeSeverityError, Text: msg})
+			response.Frames = append(response.Frames, frame)
+			continue
+		}
+
+		rs, ok, err := unmarshalRows(statement.Result)
+		if err != nil {
+			return response, err
+		}
+
+		if !ok {
+			if opts.keepEmpty {
+				response.Frames = append(response.Frames, data.NewFrame("response"))
+			}
+			continue
+		}
+
+		// every row is shaped into at least one row, so the rows past the
+		// limit are dropped before being shaped, and the shaped rows after
+		truncated := opts.maxRows > 0 && len(rs.rows) > opts.maxRows
+		if truncated {
+			rs.rows = rs.rows[:opts.maxRows]
+		}
+
+		rs = shapeRows(rs, opts)
+		if opts.maxRows > 0 && len(rs.rows) > opts.maxRows {
+			rs.rows = rs.rows[:opts.maxRows]
+			truncated = true
+		}
 
-	// convert the response to a data frame.
-	frame := toDataFrame(res)
+		rs = convertGeometries(rs)
 
-	// add the fields to the frame.
-	response.Frames = append(response.Frames, frame)
+		records := recordColumns(rs)
+		if opts.splitRecordIDs {
+			rs = splitRecordIDs(rs, records)
+		}
+
+		// convert the response to a data frame.
+		frame := toDataFrame(rs)
+		linkRecordIDs(frame, records, opts.datasource)
+		if truncated {
+			frame.AppendNotices(data.Notice{
+				Severity: data.NoticeSeverityWarning,
+				Text:     fmt.Sprintf("The result was truncated to %d rows", opts.maxRows),
+			})
+		}
+		response.Frames = append(response.Frames, frame)
+	}
+
+	if len(failed) > 0 && len(failed) == len(statements) {
+		return backend.DataResponse{}, fmt.Errorf("%w: %s", surrealdb.ErrQuery, strings.Join(failed, "; "))
+	}
 
 	return response, nil
 }
 
-// toDataFrame converts the response from the database into a data frame.
-func toDataFrame(resp []map[string]json.RawMessage) *data.Frame {
-	// @adamyeats: TODO: what should the name here be?
-	frame := data.NewFrame("response")
+// unmarshalStatements unmarshals the raw response of a query into the results
+// of each statement.
+func unmarshalStatements(result interface{}) ([]surrealdb.RawQuery[json.RawMessage], error) {
+	var statements []surrealdb.RawQuery[json.RawMessage]
 
-	buckets := map[string][]json.RawMessage{}
+	b, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed raw unmarshaling to interface slice: %w", surrealdb.InvalidResponse)
+	}
 
-	if len(resp) == 0 {
-		return frame
+	if err := json.Unmarshal(b, &statements); err != nil {
+		return nil, fmt.Errorf("failed raw unmarshaling to interface slice: %w", surrealdb.InvalidResponse)
+	}
+
+	return statements, nil
+}
+
+// statementError returns the error message of a failed statement.
+func statementError(statement surrealdb.RawQuery[json.RawMessage]) string {
+	if statement.Detail != "" {
+		return statement.Detail
+	}
+
+	// SurrealDB v1 returns the error message as the result of the statement
+	var msg string
+	if err := json.Unmarshal(statement.Result, &msg); err == nil && msg != "" {
+		return msg
+	}
+
+	return fmt.Sprintf("statement failed with status %s", statement.Status)
+}
+
+// rowSet is the rows of the result of a statement along with their columns.
+// Columns are in the order their keys first appear in the JSON objects of the
+// rows, which follows the projection of the `SELECT` statement. Rows may lack
+// some of the columns.
+type rowSet struct {
+	columns []string
+	rows    []map[string]json.RawMessage
+}
+
+// unmarshalRows unmarshals the result of a statement into a set of rows.
+// Each row is a map of column name to value. The value is a `json.RawMessage`.
+// A single object is treated as a single row, and values which are not
+// objects, such as the results of `SELECT VALUE`, become rows with a single
+// `value` column; ok is false when the statement returned no rows.
+func unmarshalRows(result json.RawMessage) (rs rowSet, ok bool, err error) {
+	trimmed := bytes.TrimSpace(result)
+
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return rs, false, nil
+	}
+
+	values := []json.RawMessage{trimmed}
+
+	if trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &values); err != nil {
+			return rs, false, fmt.Errorf("failed to unmarshal: %w", err)
+		}
 	}
 
-	for _, entity := range resp {
-		for k, v := range entity {
-			buckets[k] = append(buckets[k], v)
+	rs.rows = make([]map[string]json.RawMessage, 0, len(values))
+
+	for _, value := range values {
+		value = bytes.TrimSpace(value)
+
+		if len(value) == 0 || value[0] != '{' {
+			rs.rows = append(rs.rows, map[string]json.RawMessage{valueColumnName: value})
+			rs.columns = mergeColumns(rs.columns, []string{valueColumnName})
+			continue
+		}
+
+		keys, row, err := unmarshalObject(value)
+		if err != nil {
+			return rs, false, fmt.Errorf("failed to unmarshal: %w", err)
+		}
+		rs.rows = append(rs.rows, row)
+		rs.columns = mergeColumns(rs.columns, keys)
+	}
+
+	return rs, len(rs.rows) > 0, nil
+}
+
+// unmarshalObject unmarshals a JSON object into its values by key, along with
+// its keys in the order they appear in the object.
+func unmarshalObject(object json.RawMessage) ([]string, map[string]json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(object))
+
+	if t, err := dec.Token(); err != nil {
+		return nil, nil, err
+	} else if t != json.Delim('{') {
+		return nil, nil, fmt.Errorf("expected an object, got %v", t)
+	}
+
+	var keys []string
+	values := map[string]json.RawMessage{}
+
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, _ := t.(string)
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
 		}
+		values[key] = value
 	}
 
-	for key, vals := range buckets {
-		field := data.NewField(key, nil, vals)
-		frame.Fields = append(frame.Fields, field)
+	return keys, values, nil
+}
+
+// mergeColumns adds the keys of a row which are missing from columns, each
+// after the key preceding it in the row, so columns keep the order of the rows.
+func mergeColumns(columns []string, keys []string) []string {
+	if slices.Equal(columns, keys) {
+		return columns
+	}
+
+	pos := 0
+	for _, key := range keys {
+		if i := slices.Index(columns, key); i >= 0 {
+			pos = i + 1
+			continue
+		}
+		columns = slices.Insert(columns, pos, key)
+		pos++
+	}
+
+	return columns
+}
+
+// toDataFrame converts the response from the database into a data frame, with
+// a field per column. Rows lacking a column have a null value in its field.
+func toDataFrame(rs rowSet) *data.Frame {
+	// @adamyeats: TODO: what should the name here be?
+	frame := data.NewFrame("response")
+
+	if len(rs.rows) == 0 {
+		return frame
+	}
+
+	for _, column := range rs.columns {
+		values := make([]json.RawMessage, len(rs.rows))
+		for i, row := range rs.rows {
+			values[i] = row[column]
+		}
+		frame.Fields = append(frame.Fields, newTypedField(column, values))
 	}
-	sort.Slice(frame.Fields, func(i, j int) bool {
-		return frame.Fields[i].Name < frame.Fields[j].Name
-	})
 
 	return frame
 }
@@ -2,13 +2,17 @@ package plugin_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/grafana-labs/surrealdb-datasource/internal/mocks"
 	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
 	"github.com/grafana-labs/surrealdb-datasource/pkg/plugin"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 )
 
 func TestCreateDataResponse_QueryError(t *testing.T) {
@@ -111,3 +115,482 @@ func TestCreateDataResponse_Success(t *testing.T) {
 		t.Errorf("expected second field name to be 'column2', got %v", response.Frames[0].Fields[1].Name)
 	}
 }
+
+func TestCreateDataResponse_FieldTypes(t *testing.T) {
+	query := backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"rawSql": "SELECT * FROM test"}`),
+	}
+
+	successMock := mocks.MockSurrealDBClient{
+		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+			return []interface{}{
+				map[string]interface{}{
+					"status": "OK",
+					"result": []interface{}{
+						map[string]interface{}{
+							"active":  true,
+							"count":   1,
+							"created": "2023-11-27T22:30:23Z",
+							"mixed":   "one",
+							"name":    "first",
+							"nested":  map[string]interface{}{"a": 1},
+							"value":   1,
+						},
+						map[string]interface{}{
+							"active":  false,
+							"count":   nil,
+							"created": "2023-11-27T22:31:23.5Z",
+							"mixed":   2,
+							"name":    "second",
+							"nested":  []interface{}{1, 2},
+							"value":   1.5,
+						},
+					},
+					"time": "10ms",
+				},
+			}, nil
+		},
+	}
+
+	ds := plugin.NewDatasourceInstance(client.Use(&successMock), &config)
+
+	response := ds.CreateDataResponse(context.TODO(), query)
+
+	if response.Error != nil {
+		t.Fatalf("unexpected error: %s", response.Error)
+	}
+
+	expected := map[string]data.FieldType{
+		"active":  data.FieldTypeBool,
+		"count":   data.FieldTypeNullableInt64,
+		"created": data.FieldTypeTime,
+		"mixed":   data.FieldTypeNullableString,
+		"name":    data.FieldTypeString,
+		"nested":  data.FieldTypeJSON,
+		"value":   data.FieldTypeFloat64,
+	}
+
+	frame := response.Frames[0]
+
+	for name, fieldType := range expected {
+		field, _ := frame.FieldByName(name)
+		if field == nil {
+			t.Errorf("expected field %q to exist", name)
+			continue
+		}
+		if field.Type() != fieldType {
+			t.Errorf("expected field %q to be of type %s, got %s", name, fieldType, field.Type())
+		}
+	}
+
+	mixed, _ := frame.FieldByName("mixed")
+	if v := mixed.At(1).(*string); *v != "2" {
+		t.Errorf("expected mixed value '2', got %v", *v)
+	}
+}
+
+func TestCreateDataResponse_QueryVars(t *testing.T) {
+	query := backend.DataQuery{
+		RefID:         "A",
+		JSON:          []byte(`{"rawSql": "SELECT * FROM metrics WHERE time >= $from AND time <= $to"}`),
+		Interval:      time.Minute,
+		MaxDataPoints: 100,
+		TimeRange: backend.TimeRange{
+			From: time.Date(2023, 11, 27, 22, 30, 23, 0, time.UTC),
+			To:   time.Date(2023, 11, 28, 22, 30, 23, 0, time.UTC),
+		},
+	}
+
+	var vars map[string]interface{}
+
+	mock := mocks.MockSurrealDBClient{
+		QueryFunc: func(sql string, v interface{}) (interface{}, error) {
+			vars = v.(map[string]interface{})
+			return []interface{}{
+				map[string]interface{}{"status": "OK", "result": []interface{}{}, "time": "1ms"},
+			}, nil
+		},
+	}
+
+	ds := plugin.NewDatasourceInstance(client.Use(&mock), &config)
+
+	response := ds.CreateDataResponse(context.TODO(), query)
+
+	if response.Error != nil {
+		t.Fatalf("unexpected error: %s", response.Error)
+	}
+
+	expected := map[string]interface{}{
+		"from":            "2023-11-27T22:30:23Z",
+		"to":              "2023-11-28T22:30:23Z",
+		"interval":        "1m",
+		"interval_ms":     int64(60000),
+		"max_data_points": int64(100),
+		"ref_id":          "A",
+	}
+
+	for key, value := range expected {
+		if vars[key] != value {
+			t.Errorf("expected $%s to be %v, got %v", key, value, vars[key])
+		}
+	}
+}
+
+func TestCreateDataResponse_TypedParams(t *testing.T) {
+	query := backend.DataQuery{
+		RefID:    "A",
+		JSON:     []byte(`{"rawSql": "SELECT * FROM metrics WHERE time >= $from AND time <= $to GROUP BY time::floor(time, $interval)"}`),
+		Interval: 90 * time.Second,
+		TimeRange: backend.TimeRange{
+			From: time.Date(2023, 11, 27, 22, 30, 23, 0, time.UTC),
+			To:   time.Date(2023, 11, 28, 22, 30, 23, 0, time.UTC),
+		},
+	}
+
+	var sql string
+
+	mock := mocks.MockSurrealDBClient{
+		QueryFunc: func(s string, v interface{}) (interface{}, error) {
+			sql = s
+			return []interface{}{
+				map[string]interface{}{"status": "OK", "result": nil, "time": "1ms"},
+				map[string]interface{}{"status": "OK", "result": nil, "time": "1ms"},
+				map[string]interface{}{"status": "OK", "result": nil, "time": "1ms"},
+				map[string]interface{}{"status": "OK", "result": []interface{}{map[string]interface{}{"value": 1}}, "time": "1ms"},
+			}, nil
+		},
+	}
+
+	ds := plugin.NewDatasourceInstance(client.Use(&mock), &config)
+
+	response := ds.CreateDataResponse(context.TODO(), query)
+
+	if response.Error != nil {
+		t.Fatalf("unexpected error: %s", response.Error)
+	}
+
+	expected := "LET $from = d'2023-11-27T22:30:23Z';\n" +
+		"LET $to = d'2023-11-28T22:30:23Z';\n" +
+		"LET $interval = 1m30s;\n" +
+		"SELECT * FROM metrics WHERE time >= $from AND time <= $to GROUP BY time::floor(time, $interval)"
+	if sql != expected {
+		t.Errorf("expected %q, got %q", expected, sql)
+	}
+
+	if len(response.Frames) != 1 || response.Frames[0].Fields[0].Name != "value" {
+		t.Errorf("expected only the frame of the query, got %d frames", len(response.Frames))
+	}
+
+	t.Run("interval_ms only", func(t *testing.T) {
+		query.JSON = []byte(`{"rawSql": "SELECT * FROM metrics LIMIT $interval_ms"}`)
+		ds.CreateDataResponse(context.TODO(), query)
+
+		if sql != "SELECT * FROM metrics LIMIT $interval_ms" {
+			t.Errorf("expected no typed parameters, got %q", sql)
+		}
+	})
+}
+
+func TestCreateDataResponse_MultipleStatements(t *testing.T) {
+	query := backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"rawSql": "LET $x = 1; SELECT * FROM a; SELECT * FROM b; SELECT * FROM c;"}`),
+	}
+
+	successMock := mocks.MockSurrealDBClient{
+		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+			return []interface{}{
+				map[string]interface{}{"status": "OK", "result": nil, "time": "1ms"},
+				map[string]interface{}{
+					"status": "OK",
+					"result": []interface{}{map[string]interface{}{"a": 1}},
+					"time":   "1ms",
+				},
+				map[string]interface{}{"status": "ERR", "result": "There was a problem with the database", "time": "1ms"},
+				map[string]interface{}{
+					"status": "OK",
+					"result": []interface{}{map[string]interface{}{"c": "value"}},
+					"time":   "1ms",
+				},
+			}, nil
+		},
+	}
+
+	ds := plugin.NewDatasourceInstance(client.Use(&successMock), &config)
+
+	response := ds.CreateDataResponse(context.TODO(), query)
+
+	if response.Error != nil {
+		t.Fatalf("unexpected error: %s", response.Error)
+	}
+	if len(response.Frames) != 3 {
+		t.Fatalf("expected 3 frames, got %d", len(response.Frames))
+	}
+	if response.Frames[0].Fields[0].Name != "a" {
+		t.Errorf("expected first frame to have field 'a', got %v", response.Frames[0].Fields[0].Name)
+	}
+	if response.Frames[1].Meta == nil || len(response.Frames[1].Meta.Notices) != 1 {
+		t.Fatalf("expected second frame to have a notice")
+	}
+	if notice := response.Frames[1].Meta.Notices[0]; notice.Severity != data.NoticeSeverityError || notice.Text != "There was a problem with the database" {
+		t.Errorf("unexpected notice: %+v", notice)
+	}
+	if response.Frames[2].Fields[0].Name != "c" {
+		t.Errorf("expected third frame to have field 'c', got %v", response.Frames[2].Fields[0].Name)
+	}
+}
+
+func TestCreateDataResponse_KeepEmptyResults(t *testing.T) {
+	emptyMock := mocks.MockSurrealDBClient{
+		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+			return []interface{}{
+				map[string]interface{}{"status": "OK", "result": nil, "time": "1ms"},
+				map[string]interface{}{"status": "OK", "result": []interface{}{}, "time": "1ms"},
+				map[string]interface{}{"status": "OK", "result": []interface{}{map[string]interface{}{"c": 1}}, "time": "1ms"},
+			}, nil
+		},
+	}
+
+	cases := []struct {
+		name     string
+		json     string
+		expected []int
+	}{
+		{name: "skipped by default", json: `{"rawSql": "LET $x = 1; SELECT * FROM b; SELECT * FROM c"}`, expected: []int{1}},
+		{name: "kept", json: `{"rawSql": "LET $x = 1; SELECT * FROM b; SELECT * FROM c", "keepEmptyResults": true}`, expected: []int{0, 0, 1}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := plugin.NewDatasourceInstance(client.Use(&emptyMock), &config)
+			response := ds.CreateDataResponse(context.TODO(), backend.DataQuery{RefID: "A", JSON: []byte(tt.json)})
+
+			if response.Error != nil {
+				t.Fatalf("unexpected error: %s", response.Error)
+			}
+			if len(response.Frames) != len(tt.expected) {
+				t.Fatalf("expected %d frames, got %d", len(tt.expected), len(response.Frames))
+			}
+			for i, fields := range tt.expected {
+				if len(response.Frames[i].Fields) != fields {
+					t.Errorf("expected frame %d to have %d fields, got %d", i, fields, len(response.Frames[i].Fields))
+				}
+			}
+		})
+	}
+}
+
+func TestCreateDataResponse_StatementError(t *testing.T) {
+	query := backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"rawSql": "SELECT * FROM a"}`),
+	}
+
+	errorMock := mocks.MockSurrealDBClient{
+		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+			return []interface{}{
+				map[string]interface{}{"status": "ERR", "result": "Parse error", "time": "1ms"},
+			}, nil
+		},
+	}
+
+	ds := plugin.NewDatasourceInstance(client.Use(&errorMock), &config)
+
+	response := ds.CreateDataResponse(context.TODO(), query)
+
+	if response.Error == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if response.Error.Error() != "response: error occurred processing the SurrealDB query: Parse error" {
+		t.Errorf("unexpected error message: %v", response.Error.Error())
+	}
+}
+
+func TestCreateDataResponse_ColumnOrder(t *testing.T) {
+	orderMock := mocks.MockSurrealDBClient{
+		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+			return json.RawMessage(`[{"status": "OK", "result": [
+				{"time": "2024-01-01T00:00:00Z", "value": 1, "host": "a", "meta": {"zone": "eu", "rack": 1}},
+				{"time": "2024-01-01T00:01:00Z", "host": "b", "meta": null},
+				{"time": "2024-01-01T00:02:00Z", "value": 3, "up": true, "host": "c", "meta": {"zone": "us", "rack": 2}}
+			]}]`), nil
+		},
+	}
+
+	ds := plugin.NewDatasourceInstance(client.Use(&orderMock), &config)
+	response := ds.CreateDataResponse(context.TODO(), backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"rawSql": "SELECT time, value, host, meta FROM metrics", "flattenDepth": 1}`),
+	})
+
+	if response.Error != nil {
+		t.Fatalf("unexpected error: %s", response.Error)
+	}
+
+	frame := response.Frames[0]
+	expected := []string{"time", "value", "up", "host", "meta.zone", "meta.rack"}
+	if names := fieldNames(frame); strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected fields %v, got %v", expected, names)
+	}
+
+	for _, field := range frame.Fields {
+		if field.Len() != 3 {
+			t.Errorf("expected field %s to have 3 values, got %d", field.Name, field.Len())
+		}
+	}
+
+	hosts, _ := frame.FieldByName("host")
+	values, _ := frame.FieldByName("value")
+	zones, _ := frame.FieldByName("meta.zone")
+	for i, host := range []string{"a", "b", "c"} {
+		if got, _ := hosts.ConcreteAt(i); got != host {
+			t.Errorf("expected host %q in row %d, got %v", host, i, got)
+		}
+	}
+	if v, ok := values.ConcreteAt(1); ok {
+		t.Errorf("expected a null value in row 1, got %v", v)
+	}
+	if v, _ := values.ConcreteAt(2); v != int64(3) {
+		t.Errorf("expected value 3 in row 2, got %v", v)
+	}
+	if z, ok := zones.ConcreteAt(1); ok {
+		t.Errorf("expected a null zone in row 1, got %v", z)
+	}
+}
+
+func TestCreateDataResponse_MaxRows(t *testing.T) {
+	cases := []struct {
+		name     string
+		maxRows  int
+		json     string
+		expected int
+		limit    string
+	}{
+		{
+			name:     "datasource setting",
+			maxRows:  2,
+			json:     `{"rawSql": "SELECT * FROM metrics"}`,
+			expected: 2,
+			limit:    "SELECT * FROM metrics LIMIT 3",
+		},
+		{
+			name:     "query override",
+			maxRows:  2,
+			json:     `{"rawSql": "SELECT * FROM metrics", "maxRows": 1}`,
+			expected: 1,
+			limit:    "SELECT * FROM metrics LIMIT 2",
+		},
+		{
+			name:     "higher query override",
+			maxRows:  2,
+			json:     `{"rawSql": "SELECT * FROM metrics", "maxRows": 100}`,
+			expected: 2,
+			limit:    "SELECT * FROM metrics LIMIT 3",
+		},
+		{
+			name:     "query override without datasource setting",
+			json:     `{"rawSql": "SELECT * FROM metrics", "maxRows": 1}`,
+			expected: 1,
+			limit:    "SELECT * FROM metrics LIMIT 2",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent string
+
+			rowsMock := mocks.MockSurrealDBClient{
+				QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+					sent = sql
+					return []interface{}{
+						map[string]interface{}{
+							"status": "OK",
+							"result": []interface{}{
+								map[string]interface{}{"value": 1},
+								map[string]interface{}{"value": 2},
+								map[string]interface{}{"value": 3},
+							},
+						},
+					}, nil
+				},
+			}
+
+			limitedConfig := config
+			limitedConfig.MaxRows = tt.maxRows
+
+			ds := plugin.NewDatasourceInstance(client.Use(&rowsMock), &limitedConfig)
+			response := ds.CreateDataResponse(context.TODO(), backend.DataQuery{RefID: "A", JSON: []byte(tt.json)})
+
+			if response.Error != nil {
+				t.Fatalf("unexpected error: %s", response.Error)
+			}
+			if sent != tt.limit {
+				t.Errorf("expected query %q, got %q", tt.limit, sent)
+			}
+
+			frame := response.Frames[0]
+			if rows := frame.Rows(); rows != tt.expected {
+				t.Errorf("expected %d rows, got %d", tt.expected, rows)
+			}
+			if frame.Meta == nil || len(frame.Meta.Notices) != 1 {
+				t.Fatalf("expected a notice on the truncated frame, got %v", frame.Meta)
+			}
+			if notice := frame.Meta.Notices[0]; notice.Severity != data.NoticeSeverityWarning {
+				t.Errorf("expected a warning, got %v", notice.Severity)
+			}
+		})
+	}
+}
+
+func TestCreateDataResponse_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	sent := make(chan string, 1)
+
+	blockingMock := mocks.MockSurrealDBClient{
+		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+			sent <- sql
+			<-release
+			return []interface{}{}, nil
+		},
+	}
+
+	timeoutConfig := config
+	timeoutConfig.QueryTimeout = 1
+
+	ds := plugin.NewDatasourceInstance(client.Use(&blockingMock), &timeoutConfig)
+	response := ds.CreateDataResponse(context.TODO(), backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"rawSql": "SELECT * FROM metrics"}`),
+	})
+
+	if response.Status != backend.StatusTimeout {
+		t.Errorf("expected status timeout, got %v", response.Status)
+	}
+	if response.Error == nil || response.Error.Error() != "query: timed out after 1s" {
+		t.Errorf("expected timeout error, got %v", response.Error)
+	}
+	if sql := <-sent; !strings.HasPrefix(sql, "SELECT * FROM metrics TIMEOUT ") {
+		t.Errorf("expected a TIMEOUT clause to be added, got %q", sql)
+	}
+}
+
+func TestCreateDataResponse_TransportTimeout(t *testing.T) {
+	timeoutMock := mocks.MockSurrealDBClient{
+		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+			return nil, client.ErrTimeout
+		},
+	}
+
+	ds := plugin.NewDatasourceInstance(client.Use(&timeoutMock), &config)
+	response := ds.CreateDataResponse(context.TODO(), backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"rawSql": "SELECT * FROM metrics"}`),
+	})
+
+	if response.Status != backend.StatusTimeout {
+		t.Errorf("expected status timeout, got %v", response.Status)
+	}
+}
@@ -0,0 +1,215 @@
+package plugin
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const (
+	// recordTableSuffix and recordIDSuffix are the suffixes of the columns
+	// holding the table and the id of the record IDs of a column.
+	recordTableSuffix = ".tb"
+	recordIDSuffix    = ".id"
+)
+
+// recordIDPattern matches the record IDs and record links returned by
+// SurrealDB, e.g. `person:aaron`, `person:⟨john doe⟩` or `temperature:['London', 1]`.
+// Escaped identifiers cannot contain their closing character, nor a `\`, which
+// would escape it, and no part of a record ID can contain a `;`; the brackets
+// of array and object ids are checked by isBalanced.
+var recordIDPattern = regexp.MustCompile("^([A-Za-z_][A-Za-z0-9_]*|⟨[^⟩;\\\\]+⟩|`[^`;\\\\]+`):([A-Za-z0-9_]+|⟨[^⟩;\\\\]+⟩|`[^`;\\\\]+`|\\{[^;]*\\}|\\[[^;]*\\])$")
+
+// datasourceRef identifies the datasource the data links of record IDs query.
+type datasourceRef struct {
+	uid  string
+	name string
+}
+
+// parseRecordID returns the table and the id of a record ID, without the
+// escaping of identifiers.
+func parseRecordID(s string) (table string, id string, ok bool) {
+	table, id, ok = recordIDParts(s)
+	if !ok {
+		return "", "", false
+	}
+
+	return unescapeIdent(table), unescapeIdent(id), true
+}
+
+// recordIDParts returns the table and the id of a record ID as they are written,
+// with the escaping of identifiers.
+func recordIDParts(s string) (table string, id string, ok bool) {
+	m := recordIDPattern.FindStringSubmatch(s)
+	if m == nil || !isBalanced(m[2]) {
+		return "", "", false
+	}
+
+	return m[1], m[2], true
+}
+
+// isRecordIDLiteral reports whether s is a record ID which can be written as it
+// is in a query: ids of arrays and objects are left out, since they can hold
+// expressions rather than values, e.g. `<future> { ... }`.
+func isRecordIDLiteral(s string) bool {
+	_, id, ok := recordIDParts(s)
+
+	return ok && id[0] != '[' && id[0] != '{'
+}
+
+// isBalanced reports whether the brackets of the id of a record ID are balanced,
+// the first bracket closing at the end of the id, outside of quoted strings,
+// and whether it has no parentheses, so that array and object ids hold values
+// rather than subqueries. Other ids have no brackets to check.
+func isBalanced(id string) bool {
+	if id[0] != '[' && id[0] != '{' {
+		return true
+	}
+
+	var stack []rune
+	var quote rune
+	escaped := false
+
+	for i, r := range id {
+		switch {
+		case quote != 0:
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == quote:
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '[' || r == '{':
+			stack = append(stack, r)
+		case r == ']' || r == '}':
+			if len(stack) == 0 || (r == ']') != (stack[len(stack)-1] == '[') {
+				return false
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 && i != len(id)-1 {
+				return false
+			}
+		case r == '(' || r == ')':
+			return false
+		}
+	}
+
+	return quote == 0 && len(stack) == 0
+}
+
+// unescapeIdent removes the angle brackets or backticks around an identifier.
+func unescapeIdent(ident string) string {
+	if strings.HasPrefix(ident, "⟨") && strings.HasSuffix(ident, "⟩") {
+		return strings.TrimSuffix(strings.TrimPrefix(ident, "⟨"), "⟩")
+	}
+	if len(ident) >= 2 && ident[0] == '`' && ident[len(ident)-1] == '`' {
+		return ident[1 : len(ident)-1]
+	}
+
+	return ident
+}
+
+// recordColumns returns the columns of the rows whose values are all record
+// IDs, ignoring nulls.
+func recordColumns(rs rowSet) []string {
+	var columns []string
+
+	for _, column := range rs.columns {
+		if isRecordColumn(rs.rows, column) {
+			columns = append(columns, column)
+		}
+	}
+
+	return columns
+}
+
+// isRecordColumn reports whether a column has record IDs and no other values
+// than nulls.
+func isRecordColumn(rows []map[string]json.RawMessage, column string) bool {
+	found := false
+
+	for _, row := range rows {
+		v := decodeValue(row[column])
+		if v.kind == kindNull {
+			continue
+		}
+
+		s, ok := v.value.(string)
+		if !ok {
+			return false
+		}
+		if _, _, ok := parseRecordID(s); !ok {
+			return false
+		}
+		found = true
+	}
+
+	return found
+}
+
+// splitRecordIDs adds the table and the id of the record IDs of each record
+// column as two columns following it, e.g. `author.tb` and `author.id`.
+func splitRecordIDs(rs rowSet, records []string) rowSet {
+	for _, column := range records {
+		tableColumn, idColumn := column+recordTableSuffix, column+recordIDSuffix
+		if slices.Contains(rs.columns, tableColumn) || slices.Contains(rs.columns, idColumn) {
+			continue
+		}
+
+		for _, row := range rs.rows {
+			var s string
+			if err := json.Unmarshal(row[column], &s); err != nil {
+				continue
+			}
+			table, id, ok := parseRecordID(s)
+			if !ok {
+				// nulls leave the table and the id null
+				continue
+			}
+			row[tableColumn], _ = json.Marshal(table)
+			row[idColumn], _ = json.Marshal(id)
+		}
+
+		i := slices.Index(rs.columns, column)
+		rs.columns = slices.Insert(rs.columns, i+1, tableColumn, idColumn)
+	}
+
+	return rs
+}
+
+// linkRecordIDs adds a data link to the fields of the record columns of a
+// frame, opening the record in Explore with `SELECT * FROM <id>`.
+func linkRecordIDs(frame *data.Frame, records []string, ds datasourceRef) {
+	if ds.uid == "" {
+		return
+	}
+
+	for _, column := range records {
+		field, _ := frame.FieldByName(column)
+		if field == nil {
+			continue
+		}
+
+		if field.Config == nil {
+			field.Config = &data.FieldConfig{}
+		}
+		field.Config.Links = append(field.Config.Links, data.DataLink{
+			Title: "Explore ${__value.raw}",
+			Internal: &data.InternalDataLink{
+				DatasourceUID:  ds.uid,
+				DatasourceName: ds.name,
+				Query: SurrealQuery{
+					RawSQL: "SELECT * FROM ${__value.raw}",
+					Format: FormatTable,
+				},
+			},
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package plugin_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/grafana-labs/surrealdb-datasource/internal/mocks"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/plugin"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// postMock returns posts with record IDs and record links to their authors.
+var postMock = mocks.MockSurrealDBClient{
+	QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+		return []interface{}{
+			map[string]interface{}{
+				"status": "OK",
+				"result": []interface{}{
+					map[string]interface{}{"id": "post:first", "author": "person:aaron", "title": "Hello: world"},
+					map[string]interface{}{"id": "post:⟨second post⟩", "author": nil, "title": "Drafts"},
+				},
+			},
+		}, nil
+	},
+}
+
+func TestCreateDataResponse_RecordIDs(t *testing.T) {
+	ctx := backend.WithPluginContext(context.TODO(), backend.PluginContext{
+		DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{UID: "surreal", Name: "SurrealDB"},
+	})
+
+	t.Run("data links", func(t *testing.T) {
+		ds := plugin.NewDatasourceInstance(client.Use(&postMock), &config)
+		response := ds.CreateDataResponse(ctx, backend.DataQuery{RefID: "A", JSON: []byte(`{"rawSql": "SELECT * FROM post"}`)})
+
+		if response.Error != nil {
+			t.Fatalf("unexpected error: %s", response.Error)
+		}
+
+		frame := response.Frames[0]
+		if names := strings.Join(fieldNames(frame), ","); names != "author,id,title" {
+			t.Fatalf("expected the fields author,id,title, got %s", names)
+		}
+
+		for _, name := range []string{"author", "id"} {
+			field, _ := frame.FieldByName(name)
+			if field.Config == nil || len(field.Config.Links) != 1 {
+				t.Fatalf("expected a data link on %s, got %v", name, field.Config)
+			}
+
+			link := field.Config.Links[0]
+			if link.Internal == nil || link.Internal.DatasourceUID != "surreal" {
+				t.Fatalf("expected an internal link to the datasource, got %+v", link)
+			}
+			if query := link.Internal.Query.(plugin.SurrealQuery); query.RawSQL != "SELECT * FROM ${__value.raw}" {
+				t.Errorf("unexpected link query %q", query.RawSQL)
+			}
+		}
+
+		title, _ := frame.FieldByName("title")
+		if title.Config != nil && len(title.Config.Links) > 0 {
+			t.Errorf("expected no data link on title, got %v", title.Config.Links)
+		}
+	})
+
+	t.Run("without datasource", func(t *testing.T) {
+		ds := plugin.NewDatasourceInstance(client.Use(&postMock), &config)
+		response := ds.CreateDataResponse(context.TODO(), backend.DataQuery{RefID: "A", JSON: []byte(`{"rawSql": "SELECT * FROM post"}`)})
+
+		if response.Error != nil {
+			t.Fatalf("unexpected error: %s", response.Error)
+		}
+
+		id, _ := response.Frames[0].FieldByName("id")
+		if id.Config != nil && len(id.Config.Links) > 0 {
+			t.Errorf("expected no data link without a datasource, got %v", id.Config.Links)
+		}
+	})
+
+	t.Run("split", func(t *testing.T) {
+		ds := plugin.NewDatasourceInstance(client.Use(&postMock), &config)
+		response := ds.CreateDataResponse(ctx, backend.DataQuery{
+			RefID: "A",
+			JSON:  []byte(`{"rawSql": "SELECT * FROM post", "splitRecordIds": true}`),
+		})
+
+		if response.Error != nil {
+			t.Fatalf("unexpected error: %s", response.Error)
+		}
+
+		frame := response.Frames[0]
+		expected := "author,author.tb,author.id,id,id.tb,id.id,title"
+		if names := strings.Join(fieldNames(frame), ","); names != expected {
+			t.Fatalf("expected the fields %s, got %s", expected, names)
+		}
+
+		cases := []struct {
+			field string
+			row   int
+			value interface{}
+			ok    bool
+		}{
+			{field: "author.tb", row: 0, value: "person", ok: true},
+			{field: "author.id", row: 0, value: "aaron", ok: true},
+			{field: "author.id", row: 1, ok: false},
+			{field: "id.tb", row: 1, value: "post", ok: true},
+			{field: "id.id", row: 1, value: "second post", ok: true},
+		}
+
+		for _, tt := range cases {
+			field, _ := frame.FieldByName(tt.field)
+			value, ok := field.ConcreteAt(tt.row)
+			if ok != tt.ok || (ok && value != tt.value) {
+				t.Errorf("expected %s in row %d to be %v, got %v", tt.field, tt.row, tt.value, value)
+			}
+		}
+	})
+}
+
+func TestCreateDataResponse_RecordIDPattern(t *testing.T) {
+	ctx := backend.WithPluginContext(context.TODO(), backend.PluginContext{
+		DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{UID: "surreal", Name: "SurrealDB"},
+	})
+
+	cases := []struct {
+		name   string
+		values []interface{}
+		linked bool
+	}{
+		{name: "identifier", values: []interface{}{"person:aaron"}, linked: true},
+		{name: "escaped", values: []interface{}{"person:`john doe`", "⟨my table⟩:⟨john doe⟩"}, linked: true},
+		{name: "array", values: []interface{}{"temperature:['London', d'2022-08-29T08:03:39Z']"}, linked: true},
+		{name: "object", values: []interface{}{"temperature:{ location: 'London', tags: ['a]', \"b\"] }"}, linked: true},
+		{name: "statement after backticks", values: []interface{}{"x:`a` ; DELETE y"}},
+		{name: "statement after angle brackets", values: []interface{}{"x:⟨a⟩; DELETE y"}},
+		{name: "escaped closing angle bracket", values: []interface{}{`x:⟨a\⟩`, `⟨a\⟩:b`}},
+		{name: "escaped closing backtick", values: []interface{}{"x:`a\\`"}},
+		{name: "statement after array", values: []interface{}{"x:[1]; DELETE y"}},
+		{name: "semicolon in string", values: []interface{}{"x:['a;b']"}},
+		{name: "unbalanced array", values: []interface{}{"x:[1, [2]"}},
+		{name: "several arrays", values: []interface{}{"x:[1] + [2]"}},
+		{name: "mismatched brackets", values: []interface{}{"x:{a: [1}]"}},
+		{name: "unterminated string", values: []interface{}{"x:['a]"}},
+		{name: "subquery", values: []interface{}{"x:[(DELETE y)]"}},
+		{name: "some values", values: []interface{}{"person:aaron", "x:`a` ; DELETE y"}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := make([]interface{}, len(tt.values))
+			for i, v := range tt.values {
+				rows[i] = map[string]interface{}{"ref": v}
+			}
+
+			refMock := mocks.MockSurrealDBClient{
+				QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+					return []interface{}{map[string]interface{}{"status": "OK", "result": rows}}, nil
+				},
+			}
+
+			ds := plugin.NewDatasourceInstance(client.Use(&refMock), &config)
+			response := ds.CreateDataResponse(ctx, backend.DataQuery{RefID: "A", JSON: []byte(`{"rawSql": "SELECT ref FROM x"}`)})
+
+			if response.Error != nil {
+				t.Fatalf("unexpected error: %s", response.Error)
+			}
+
+			field, _ := response.Frames[0].FieldByName("ref")
+			if linked := field.Config != nil && len(field.Config.Links) > 0; linked != tt.linked {
+				t.Errorf("expected linked to be %t, got %t", tt.linked, linked)
+			}
+		})
+	}
+}
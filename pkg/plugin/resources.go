@@ -0,0 +1,407 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/grafana-labs/surrealdb-datasource/pkg/surrealql"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+)
+
+// identRegex matches identifiers which can be used in SurrealQL without escaping.
+var identRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// defaultTagValuesLimit is the maximum number of values of an ad-hoc filter key
+// read from each table when the datasource does not limit the rows of queries.
+const defaultTagValuesLimit = 1000
+
+// Field describes a field defined on a table.
+type Field struct {
+	Name       string `json:"name"`
+	Type       string `json:"type,omitempty"`
+	Definition string `json:"definition"`
+}
+
+// TagKey is the key of ad-hoc filters on a field, with the type of its values.
+type TagKey struct {
+	Text string    `json:"text"`
+	Type ValueType `json:"type,omitempty"`
+}
+
+// Index describes an index defined on a table.
+type Index struct {
+	Name       string `json:"name"`
+	Definition string `json:"definition"`
+}
+
+// newResourceHandler returns the handler of the schema introspection routes
+// used by the query editor for autocompletion.
+func (d *SurrealDatasource) newResourceHandler() backend.CallResourceHandler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /namespaces", d.handleNamespaces)
+	mux.HandleFunc("GET /databases", d.handleDatabases)
+	mux.HandleFunc("GET /tables", d.handleTables)
+	mux.HandleFunc("GET /tables/{name}/fields", d.handleFields)
+	mux.HandleFunc("GET /tables/{name}/indexes", d.handleIndexes)
+	mux.HandleFunc("GET /tag-keys", d.handleTagKeys)
+	mux.HandleFunc("GET /tag-values", d.handleTagValues)
+
+	return httpadapter.New(mux)
+}
+
+// handleNamespaces returns the names of the namespaces.
+func (d *SurrealDatasource) handleNamespaces(w http.ResponseWriter, r *http.Request) {
+	info, err := d.info(r, "INFO FOR ROOT")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, names(info, "namespaces", "ns"))
+}
+
+// handleDatabases returns the names of the databases of the configured namespace.
+func (d *SurrealDatasource) handleDatabases(w http.ResponseWriter, r *http.Request) {
+	info, err := d.info(r, "INFO FOR NS")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, names(info, "databases", "db"))
+}
+
+// handleTables returns the names of the tables of the configured database.
+func (d *SurrealDatasource) handleTables(w http.ResponseWriter, r *http.Request) {
+	info, err := d.info(r, "INFO FOR DB")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, names(info, "tables", "tb"))
+}
+
+// handleFields returns the fields defined on a table.
+func (d *SurrealDatasource) handleFields(w http.ResponseWriter, r *http.Request) {
+	info, err := d.info(r, "INFO FOR TABLE "+escapeIdent(r.PathValue("name")))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, fieldDefinitions(info))
+}
+
+// fieldDefinitions returns the fields of the result of `INFO FOR TABLE`.
+func fieldDefinitions(info map[string]json.RawMessage) []Field {
+	definitions := section(info, "fields", "fd")
+	fields := make([]Field, 0, len(definitions))
+
+	for _, name := range sortedKeys(definitions) {
+		field := Field{Name: name, Definition: definitions[name]}
+		field.Type = surrealql.FieldType(field.Definition)
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+// handleIndexes returns the indexes defined on a table.
+func (d *SurrealDatasource) handleIndexes(w http.ResponseWriter, r *http.Request) {
+	info, err := d.info(r, "INFO FOR TABLE "+escapeIdent(r.PathValue("name")))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	definitions := section(info, "indexes", "ix")
+	indexes := make([]Index, 0, len(definitions))
+
+	for _, name := range sortedKeys(definitions) {
+		indexes = append(indexes, Index{Name: name, Definition: definitions[name]})
+	}
+
+	writeJSON(w, indexes)
+}
+
+// handleTagKeys returns the keys of ad-hoc filters: the fields defined on the
+// tables of the configured database, with the type of their values when all
+// the tables defining them agree on it.
+func (d *SurrealDatasource) handleTagKeys(w http.ResponseWriter, r *http.Request) {
+	fields, err := d.tableFields(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	types := map[string]ValueType{}
+	for _, table := range sortedKeys(fields) {
+		for _, field := range fields[table] {
+			typ, ok := types[field.Name]
+			switch {
+			case !ok:
+				types[field.Name] = valueType(field.Type)
+			case typ != valueType(field.Type):
+				types[field.Name] = ValueTypeString
+			}
+		}
+	}
+
+	keys := make([]TagKey, 0, len(types))
+	for _, name := range sortedKeys(types) {
+		keys = append(keys, TagKey{Text: name, Type: types[name]})
+	}
+
+	writeJSON(w, keys)
+}
+
+// valueType returns the type of the values of ad-hoc filters on a field of a
+// type, e.g. `option<int>`.
+func valueType(fieldType string) ValueType {
+	fieldType = strings.ToLower(fieldType)
+	if inner, ok := strings.CutPrefix(fieldType, "option<"); ok {
+		fieldType = strings.TrimSuffix(inner, ">")
+	}
+
+	switch {
+	case fieldType == "int" || fieldType == "float" || fieldType == "decimal" || fieldType == "number":
+		return ValueTypeNumber
+	case fieldType == "record" || strings.HasPrefix(fieldType, "record<"):
+		return ValueTypeRecord
+	}
+
+	return ValueTypeString
+}
+
+// handleTagValues returns the values of the ad-hoc filter key passed in the
+// `key` query parameter, from the tables on which the field is defined.
+func (d *SurrealDatasource) handleTagValues(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(w, errors.New("missing key"))
+		return
+	}
+
+	fields, err := d.tableFields(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// the values are limited and timed out as the rows of queries, so that
+	// large tables are not scanned without bounds
+	timeout, limit := d.queryLimits(&SurrealQuery{})
+	if limit <= 0 {
+		limit = defaultTagValuesLimit
+	}
+
+	var statements []string
+	for _, table := range sortedKeys(fields) {
+		for _, field := range fields[table] {
+			if field.Name == key {
+				statements = append(statements, fmt.Sprintf("SELECT %s AS tag FROM %s GROUP BY tag LIMIT %d", formatFieldPath(key), escapeIdent(table), limit))
+				break
+			}
+		}
+	}
+
+	values := map[string]bool{}
+	if len(statements) > 0 {
+		if timeout > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+
+		results, err := d.results(r, strings.Join(statements, "; "))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		for _, result := range results {
+			var rows []struct {
+				Tag json.RawMessage `json:"tag"`
+			}
+			_ = json.Unmarshal(result, &rows)
+
+			for _, row := range rows {
+				if v, ok := tagValue(row.Tag); ok {
+					values[v] = true
+				}
+			}
+		}
+	}
+
+	writeJSON(w, sortedKeys(values))
+}
+
+// tableFields returns the fields defined on each table of the configured
+// database, leaving out the definitions of array elements.
+func (d *SurrealDatasource) tableFields(r *http.Request) (map[string][]Field, error) {
+	info, err := d.info(r, "INFO FOR DB")
+	if err != nil {
+		return nil, err
+	}
+
+	tables := names(info, "tables", "tb")
+	fields := make(map[string][]Field, len(tables))
+	if len(tables) == 0 {
+		return fields, nil
+	}
+
+	statements := make([]string, len(tables))
+	for i, table := range tables {
+		statements[i] = "INFO FOR TABLE " + escapeIdent(table)
+	}
+
+	results, err := d.results(r, strings.Join(statements, "; "))
+	if err != nil {
+		return nil, err
+	}
+
+	for i, result := range results {
+		var info map[string]json.RawMessage
+		if err := json.Unmarshal(result, &info); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal: %w", err)
+		}
+
+		for _, field := range fieldDefinitions(info) {
+			if !strings.Contains(field.Name, "[") {
+				fields[tables[i]] = append(fields[tables[i]], field)
+			}
+		}
+	}
+
+	return fields, nil
+}
+
+// tagValue formats a value of a field as the value of an ad-hoc filter.
+func tagValue(raw json.RawMessage) (string, bool) {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil || v == nil {
+		return "", false
+	}
+
+	if s, ok := v.(string); ok {
+		return s, true
+	}
+
+	return string(raw), true
+}
+
+// info runs an INFO statement and returns its result.
+func (d *SurrealDatasource) info(r *http.Request, statement string) (map[string]json.RawMessage, error) {
+	results, err := d.results(r, statement)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) != 1 {
+		return nil, fmt.Errorf("expected 1 result, got %d", len(results))
+	}
+
+	var info map[string]json.RawMessage
+	if err := json.Unmarshal(results[0], &info); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal: %w", err)
+	}
+
+	return info, nil
+}
+
+// results runs the statements of a query and returns their results, failing if
+// any of the statements failed.
+func (d *SurrealDatasource) results(r *http.Request, sql string) ([]json.RawMessage, error) {
+	c, err := d.userClient(r.Context(), r.Header.Get(backend.OAuthIdentityIDTokenHeaderName))
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.QueryWithContext(r.Context(), sql, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	statements, err := unmarshalStatements(result)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]json.RawMessage, len(statements))
+	for i, statement := range statements {
+		if statement.Status != statusOK {
+			return nil, errors.New(statementError(statement))
+		}
+		results[i] = statement.Result
+	}
+
+	return results, nil
+}
+
+// section returns the definitions of a section of an INFO result, under the
+// first of its keys found: the names of the sections differ between SurrealDB
+// 1.x and 2.x, e.g. `tb` and `tables`.
+func section(info map[string]json.RawMessage, keys ...string) map[string]string {
+	definitions := map[string]string{}
+
+	for _, key := range keys {
+		if raw, ok := info[key]; ok {
+			_ = json.Unmarshal(raw, &definitions)
+			break
+		}
+	}
+
+	return definitions
+}
+
+// names returns the sorted names defined in a section of an INFO result.
+func names(info map[string]json.RawMessage, keys ...string) []string {
+	return sortedKeys(section(info, keys...))
+}
+
+// sortedKeys returns the keys of m in ascending order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// escapeIdent escapes an identifier for use in SurrealQL.
+func escapeIdent(name string) string {
+	if identRegex.MatchString(name) {
+		return name
+	}
+
+	return "`" + strings.ReplaceAll(strings.ReplaceAll(name, `\`, `\\`), "`", "\\`") + "`"
+}
+
+// writeJSON writes v as the JSON body of a response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.DefaultLogger.Error("unable to write resource response", "error", err)
+	}
+}
+
+// writeError writes err as the JSON body of a failed response.
+func writeError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
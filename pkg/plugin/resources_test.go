@@ -0,0 +1,189 @@
+package plugin_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/grafana-labs/surrealdb-datasource/internal/mocks"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/plugin"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// callResource calls a resource route of a datasource answering queries with result.
+func callResource(t *testing.T, path string, result interface{}, status string) (*backend.CallResourceResponse, string) {
+	t.Helper()
+
+	var sql string
+
+	infoMock := mocks.MockSurrealDBClient{
+		QueryFunc: func(s string, vars interface{}) (interface{}, error) {
+			sql = s
+			return []interface{}{
+				map[string]interface{}{"status": status, "result": result, "time": "1ms"},
+			}, nil
+		},
+	}
+
+	ds := plugin.NewDatasourceInstance(client.Use(&infoMock), &config)
+
+	var response *backend.CallResourceResponse
+
+	err := ds.CallResource(context.Background(), &backend.CallResourceRequest{
+		Method: http.MethodGet,
+		Path:   path,
+		URL:    path,
+	}, backend.CallResourceResponseSenderFunc(func(res *backend.CallResourceResponse) error {
+		response = res
+		return nil
+	}))
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	return response, sql
+}
+
+func TestCallResource_Tables(t *testing.T) {
+	response, sql := callResource(t, "tables", map[string]interface{}{
+		"tables": map[string]interface{}{
+			"review": "DEFINE TABLE review SCHEMALESS",
+			"person": "DEFINE TABLE person SCHEMALESS",
+		},
+	}, "OK")
+
+	if sql != "INFO FOR DB" {
+		t.Errorf("expected INFO FOR DB, got %q", sql)
+	}
+	if response.Status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", response.Status)
+	}
+
+	var tables []string
+	if err := json.Unmarshal(response.Body, &tables); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(tables) != 2 || tables[0] != "person" || tables[1] != "review" {
+		t.Errorf("expected [person review], got %v", tables)
+	}
+}
+
+func TestCallResource_Namespaces(t *testing.T) {
+	response, sql := callResource(t, "namespaces", map[string]interface{}{
+		"namespaces": map[string]interface{}{"grafana": "DEFINE NAMESPACE grafana"},
+	}, "OK")
+
+	if sql != "INFO FOR ROOT" {
+		t.Errorf("expected INFO FOR ROOT, got %q", sql)
+	}
+
+	var namespaces []string
+	if err := json.Unmarshal(response.Body, &namespaces); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(namespaces) != 1 || namespaces[0] != "grafana" {
+		t.Errorf("expected [grafana], got %v", namespaces)
+	}
+}
+
+func TestCallResource_Databases(t *testing.T) {
+	response, sql := callResource(t, "databases", map[string]interface{}{
+		"databases": map[string]interface{}{"test": "DEFINE DATABASE test"},
+	}, "OK")
+
+	if sql != "INFO FOR NS" {
+		t.Errorf("expected INFO FOR NS, got %q", sql)
+	}
+
+	var databases []string
+	if err := json.Unmarshal(response.Body, &databases); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(databases) != 1 || databases[0] != "test" {
+		t.Errorf("expected [test], got %v", databases)
+	}
+}
+
+func TestCallResource_Fields(t *testing.T) {
+	response, sql := callResource(t, "tables/person/fields", map[string]interface{}{
+		"fields": map[string]interface{}{
+			"name": "DEFINE FIELD name ON person TYPE string",
+			"age":  "DEFINE FIELD age ON person TYPE option<int> PERMISSIONS FULL",
+			"tags": "DEFINE FIELD tags ON person TYPE array<string, 10> DEFAULT []",
+			"rank": "DEFINE FIELD rank ON person TYPE int | float ASSERT $value >= 0",
+			"type": "DEFINE FIELD type ON person TYPE string",
+		},
+	}, "OK")
+
+	if sql != "INFO FOR TABLE person" {
+		t.Errorf("expected INFO FOR TABLE person, got %q", sql)
+	}
+
+	var fields []plugin.Field
+	if err := json.Unmarshal(response.Body, &fields); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(fields) != 5 {
+		t.Fatalf("expected 5 fields, got %d", len(fields))
+	}
+
+	expected := []plugin.Field{
+		{Name: "age", Type: "option<int>"},
+		{Name: "name", Type: "string"},
+		{Name: "rank", Type: "int | float"},
+		{Name: "tags", Type: "array<string, 10>"},
+		{Name: "type", Type: "string"},
+	}
+	for i, field := range expected {
+		if fields[i].Name != field.Name || fields[i].Type != field.Type {
+			t.Errorf("expected field %s of type %s, got %+v", field.Name, field.Type, fields[i])
+		}
+	}
+}
+
+func TestCallResource_FieldsOfTableNamedType(t *testing.T) {
+	response, _ := callResource(t, "tables/type/fields", map[string]interface{}{
+		"fields": map[string]interface{}{
+			"count": "DEFINE FIELD count ON type TYPE int",
+		},
+	}, "OK")
+
+	var fields []plugin.Field
+	if err := json.Unmarshal(response.Body, &fields); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(fields) != 1 || fields[0].Type != "int" {
+		t.Errorf("expected the field count of type int, got %+v", fields)
+	}
+}
+
+func TestCallResource_Indexes(t *testing.T) {
+	response, sql := callResource(t, "tables/my-table/indexes", map[string]interface{}{
+		"indexes": map[string]interface{}{
+			"email": "DEFINE INDEX email ON person FIELDS email UNIQUE",
+		},
+	}, "OK")
+
+	if sql != "INFO FOR TABLE `my-table`" {
+		t.Errorf("expected table name to be escaped, got %q", sql)
+	}
+
+	var indexes []plugin.Index
+	if err := json.Unmarshal(response.Body, &indexes); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(indexes) != 1 || indexes[0].Name != "email" {
+		t.Errorf("unexpected indexes: %+v", indexes)
+	}
+}
+
+func TestCallResource_Error(t *testing.T) {
+	response, _ := callResource(t, "tables", "You don't have permission to perform this query", "ERR")
+
+	if response.Status != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", response.Status)
+	}
+}
@@ -0,0 +1,284 @@
+package plugin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/surrealql"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+)
+
+// paramRegex matches the references to parameters, e.g. `$from`.
+var paramRegex = regexp.MustCompile(`\$(\w+)`)
+
+const (
+	// actionColumnName is the name of the column holding the action of a live query notification.
+	actionColumnName = "action"
+
+	// liveSchemaRows is the number of records selected when a live query
+	// starts to infer the fields of its frames.
+	liveSchemaRows = 100
+
+	// liveQueryExpiry is the time after which a live query registered by a data
+	// query is forgotten if its channel is not streaming, e.g. when the query
+	// came from an API call rather than a panel subscribing to the channel.
+	liveQueryExpiry = time.Minute
+)
+
+// liveQuery is a live query registered by a data query, waiting for Grafana
+// Live to subscribe to its channel.
+type liveQuery struct {
+	sql  string
+	vars map[string]interface{}
+
+	// registered is the last time a data query registered the live query, and
+	// streams the number of streams running it. Both are guarded by streamsMu.
+	registered time.Time
+	streams    int
+}
+
+// liveResponse registers a live query and returns an empty frame pointing
+// Grafana to the channel streaming the query notifications.
+func (d *SurrealDatasource) liveResponse(ctx context.Context, sql string, vars map[string]interface{}) (backend.DataResponse, error) {
+	settings := backend.PluginConfigFromContext(ctx).DataSourceInstanceSettings
+	if settings == nil {
+		return backend.DataResponse{}, errors.New("live queries require a datasource instance")
+	}
+
+	// queries with the same statement and parameters share their channel
+	vars = referencedVars(sql, vars)
+	key, err := json.Marshal(vars)
+	if err != nil {
+		return backend.DataResponse{}, err
+	}
+	sum := sha256.Sum256(append([]byte(sql+"\x00"), key...))
+	path := "live/" + hex.EncodeToString(sum[:8])
+
+	now := time.Now()
+
+	d.streamsMu.Lock()
+	// forget the channels nobody subscribed to
+	for p, q := range d.streams {
+		if q.streams == 0 && now.Sub(q.registered) > liveQueryExpiry {
+			delete(d.streams, p)
+		}
+	}
+	if q, ok := d.streams[path]; ok {
+		q.registered = now
+	} else {
+		d.streams[path] = &liveQuery{sql: sql, vars: vars, registered: now}
+	}
+	d.streamsMu.Unlock()
+
+	channel := live.Channel{
+		Scope:     live.ScopeDatasource,
+		Namespace: settings.UID,
+		Path:      path,
+	}
+
+	frame := data.NewFrame("response")
+	frame.SetMeta(&data.FrameMeta{Channel: channel.String()})
+
+	return backend.DataResponse{Frames: data.Frames{frame}}, nil
+}
+
+// referencedVars returns the parameters referenced by a query, so that the
+// time range of the dashboard only changes the channel of the live queries
+// which use it.
+func referencedVars(sql string, vars map[string]interface{}) map[string]interface{} {
+	referenced := map[string]interface{}{}
+
+	for _, m := range paramRegex.FindAllStringSubmatch(sql, -1) {
+		if v, ok := vars[m[1]]; ok {
+			referenced[m[1]] = v
+		}
+	}
+
+	return referenced
+}
+
+// SubscribeStream is called when a client subscribes to a live query channel.
+func (d *SurrealDatasource) SubscribeStream(_ context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	d.streamsMu.Lock()
+	_, ok := d.streams[req.Path]
+	d.streamsMu.Unlock()
+
+	if !ok {
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusNotFound}, nil
+	}
+
+	return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusOK}, nil
+}
+
+// PublishStream is called when a client publishes to a live query channel,
+// which is not allowed.
+func (d *SurrealDatasource) PublishStream(_ context.Context, _ *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return &backend.PublishStreamResponse{Status: backend.PublishStreamStatusPermissionDenied}, nil
+}
+
+// RunStream issues the `LIVE SELECT` of a channel and sends each notification
+// as a frame until the last subscriber leaves, then kills the live query.
+func (d *SurrealDatasource) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	d.streamsMu.Lock()
+	q, ok := d.streams[req.Path]
+	var registered time.Time
+	if ok {
+		q.streams++
+		registered = q.registered
+	}
+	d.streamsMu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("unknown live query channel: %s", req.Path)
+	}
+
+	// the channel is forgotten when the last subscriber leaves, unless a
+	// query registered it again, but kept when Grafana restarts the stream
+	// after an error
+	defer func() {
+		d.streamsMu.Lock()
+		defer d.streamsMu.Unlock()
+
+		q.streams--
+		if ctx.Err() != nil && q.streams == 0 && q.registered.Equal(registered) {
+			delete(d.streams, req.Path)
+		}
+	}()
+
+	id, notifications, err := d.client.Live(ctx, q.sql, q.vars)
+	if err != nil {
+		return fmt.Errorf("unable to start live query: %w", err)
+	}
+
+	defer func() {
+		if err := d.client.Kill(id); err != nil {
+			log.DefaultLogger.Warn("unable to kill live query", "id", id, "error", err)
+		}
+	}()
+
+	// Grafana Live drops the frames it buffered when the fields change, so
+	// all the frames of the stream have the fields of the selected records
+	schema := d.selectSchema(ctx, q)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n, ok := <-notifications:
+			if !ok {
+				return errors.New("live query connection closed")
+			}
+
+			rs := notificationRows(n)
+			if schema == nil {
+				schema = newLiveSchema(rs)
+			}
+
+			if err := sender.SendFrame(schema.frame(n.Action, rs), data.IncludeAll); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// liveSchema is the fields of the frames of a live query, following the field
+// of the action of the notifications.
+type liveSchema struct {
+	columns []string
+	kinds   []columnKind
+}
+
+// newLiveSchema returns the schema of the fields of rows. Numbers are floats,
+// since later records may have fractional values, and columns holding only
+// nulls are strings.
+func newLiveSchema(rs rowSet) *liveSchema {
+	schema := &liveSchema{columns: rs.columns, kinds: make([]columnKind, len(rs.columns))}
+
+	for i, column := range rs.columns {
+		kind := kindNull
+		for _, row := range rs.rows {
+			kind = mergeKinds(kind, decodeValue(row[column]).kind)
+		}
+		switch kind {
+		case kindNull:
+			kind = kindMixed
+		case kindInt:
+			kind = kindFloat
+		}
+		schema.kinds[i] = kind
+	}
+
+	return schema
+}
+
+// selectSchema returns the schema of the records a live query selects when it
+// starts, or nil when it selects none.
+func (d *SurrealDatasource) selectSchema(ctx context.Context, q *liveQuery) *liveSchema {
+	sql := strings.TrimSpace(q.sql)
+	if strings.HasPrefix(strings.ToUpper(sql), "LIVE ") {
+		sql = sql[len("LIVE "):]
+	}
+
+	result, err := d.client.QueryWithContext(ctx, surrealql.WithLimit(sql, liveSchemaRows), q.vars)
+	if err != nil {
+		log.DefaultLogger.Warn("unable to select the records of live query", "error", err)
+		return nil
+	}
+
+	statements, err := unmarshalStatements(result)
+	if err != nil || len(statements) != 1 || statements[0].Status != statusOK {
+		return nil
+	}
+
+	rs, ok, err := unmarshalRows(statements[0].Result)
+	if err != nil || !ok || len(rs.rows) == 0 {
+		return nil
+	}
+
+	return newLiveSchema(convertGeometries(rs))
+}
+
+// notificationRows returns the record of a live query notification as a row.
+func notificationRows(n client.Notification) rowSet {
+	if decodeValue(n.Result).kind == kindString {
+		// deletions of SurrealDB v1 only return the id of the deleted record
+		return rowSet{columns: []string{idColumnName}, rows: []map[string]json.RawMessage{{idColumnName: n.Result}}}
+	}
+
+	rs, ok, err := unmarshalRows(n.Result)
+	if err != nil || !ok || len(rs.rows) == 0 {
+		return rowSet{rows: []map[string]json.RawMessage{{}}}
+	}
+	rs.rows = rs.rows[:1]
+
+	return convertGeometries(rs)
+}
+
+// frame converts the row of a notification into a frame with the action of the
+// notification and the fields of the schema. Columns of the row missing from
+// the schema are left out, and values not matching the type of their field are
+// null.
+func (schema *liveSchema) frame(action string, rs rowSet) *data.Frame {
+	frame := data.NewFrame("response", data.NewField(actionColumnName, nil, []string{action}))
+
+	for i, column := range schema.columns {
+		values := []columnValue{{kind: kindNull}}
+		if len(rs.rows) > 0 {
+			values[0] = decodeValue(rs.rows[0][column])
+		}
+		frame.Fields = append(frame.Fields, newKindField(column, values, schema.kinds[i], true))
+	}
+
+	return frame
+}
@@ -0,0 +1,231 @@
+package plugin_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana-labs/surrealdb-datasource/internal/mocks"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/plugin"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+)
+
+type packetSender struct {
+	packets chan *backend.StreamPacket
+}
+
+func (s *packetSender) Send(p *backend.StreamPacket) error {
+	s.packets <- p
+	return nil
+}
+
+func TestLiveQuery(t *testing.T) {
+	notifications := make(chan client.Notification, 2)
+	killed := make(chan string, 1)
+
+	var liveSQL, selectSQL string
+
+	liveMock := mocks.MockSurrealDBClient{
+		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+			if !strings.HasPrefix(sql, "LIVE ") {
+				selectSQL = sql
+				return []interface{}{
+					map[string]interface{}{"status": "OK", "result": []interface{}{
+						map[string]interface{}{"id": "sensor:0", "value": 20},
+					}, "time": "1ms"},
+				}, nil
+			}
+			liveSQL = sql
+			return []interface{}{
+				map[string]interface{}{"status": "OK", "result": "live-id", "time": "1ms"},
+			}, nil
+		},
+		NotificationsFunc: func(id string) (<-chan client.Notification, error) {
+			return notifications, nil
+		},
+		KillFunc: func(id string) (interface{}, error) {
+			killed <- id
+			return nil, nil
+		},
+	}
+
+	ds := plugin.NewDatasourceInstance(client.Use(&liveMock), &config)
+
+	ctx := backend.WithPluginContext(context.Background(), backend.PluginContext{
+		DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{UID: "surreal"},
+	})
+
+	response := ds.CreateDataResponse(ctx, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"rawSql": "SELECT * FROM sensor", "live": true}`),
+	})
+
+	if response.Error != nil {
+		t.Fatalf("unexpected error: %s", response.Error)
+	}
+	if len(response.Frames) != 1 || response.Frames[0].Meta == nil {
+		t.Fatal("expected a frame with a channel")
+	}
+
+	channel, err := live.ParseChannel(response.Frames[0].Meta.Channel)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if channel.Scope != live.ScopeDatasource || channel.Namespace != "surreal" {
+		t.Errorf("unexpected channel: %s", channel.String())
+	}
+
+	subscription, err := ds.SubscribeStream(ctx, &backend.SubscribeStreamRequest{Path: channel.Path})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if subscription.Status != backend.SubscribeStreamStatusOK {
+		t.Errorf("expected subscription to be allowed, got %v", subscription.Status)
+	}
+
+	sender := &packetSender{packets: make(chan *backend.StreamPacket, 1)}
+	streamCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- ds.RunStream(streamCtx, &backend.RunStreamRequest{Path: channel.Path}, backend.NewStreamSender(sender))
+	}()
+
+	notifications <- client.Notification{
+		ID:     "live-id",
+		Action: "CREATE",
+		Result: json.RawMessage(`{"id": "sensor:1", "value": 21.5, "unit": "C"}`),
+	}
+	// deletions of SurrealDB v1 only return the id of the deleted record
+	notifications <- client.Notification{
+		ID:     "live-id",
+		Action: "DELETE",
+		Result: json.RawMessage(`"sensor:1"`),
+	}
+
+	// the frames keep the fields of the selected records
+	expected := []struct {
+		action string
+		value  interface{}
+	}{
+		{action: "CREATE", value: 21.5},
+		{action: "DELETE", value: nil},
+	}
+
+	for _, e := range expected {
+		select {
+		case packet := <-sender.packets:
+			var frame data.Frame
+			if err := json.Unmarshal(packet.Data, &frame); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if names := strings.Join(fieldNames(&frame), ","); names != "action,id,value" {
+				t.Fatalf("expected the fields action,id,value, got %s", names)
+			}
+			if frame.Fields[0].At(0) != e.action {
+				t.Errorf("expected the action %s, got %v", e.action, frame.Fields[0].At(0))
+			}
+			if id, _ := frame.Fields[1].ConcreteAt(0); id != "sensor:1" {
+				t.Errorf("expected the id sensor:1 on %s, got %v", e.action, id)
+			}
+			if typ := frame.Fields[2].Type(); typ != data.FieldTypeNullableFloat64 {
+				t.Errorf("expected value to be a nullable float, got %s", typ)
+			}
+			if value, ok := frame.Fields[2].ConcreteAt(0); (ok || e.value != nil) && value != e.value {
+				t.Errorf("expected value to be %v, got %v", e.value, value)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected a frame to be sent")
+		}
+	}
+
+	cancel()
+
+	select {
+	case id := <-killed:
+		if id != "live-id" {
+			t.Errorf("expected live query 'live-id' to be killed, got %s", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected live query to be killed")
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if !strings.HasPrefix(liveSQL, "LIVE SELECT") {
+		t.Errorf("expected a LIVE SELECT, got %q", liveSQL)
+	}
+	if selectSQL != "SELECT * FROM sensor LIMIT 100" {
+		t.Errorf("expected the records to be selected first, got %q", selectSQL)
+	}
+
+	subscription, err = ds.SubscribeStream(ctx, &backend.SubscribeStreamRequest{Path: channel.Path})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if subscription.Status != backend.SubscribeStreamStatusNotFound {
+		t.Errorf("expected the channel to be forgotten after the stream ended, got %v", subscription.Status)
+	}
+}
+
+func TestLiveQuery_Channels(t *testing.T) {
+	ds := plugin.NewDatasourceInstance(client.Use(&mock), &config)
+
+	ctx := backend.WithPluginContext(context.Background(), backend.PluginContext{
+		DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{UID: "surreal"},
+	})
+
+	channel := func(sql string, from time.Time) string {
+		model, _ := json.Marshal(plugin.SurrealQuery{RawSQL: sql, Live: true})
+		response := ds.CreateDataResponse(ctx, backend.DataQuery{
+			RefID:     "A",
+			JSON:      model,
+			TimeRange: backend.TimeRange{From: from, To: from.Add(time.Hour)},
+		})
+		if response.Error != nil {
+			t.Fatalf("unexpected error: %s", response.Error)
+		}
+		return response.Frames[0].Meta.Channel
+	}
+
+	now := time.Now()
+
+	if channel("SELECT * FROM sensor", now) != channel("SELECT * FROM sensor", now.Add(time.Minute)) {
+		t.Error("expected queries without parameters to share their channel")
+	}
+
+	sql := "SELECT * FROM sensor WHERE time > <datetime> $from"
+	if channel(sql, now) == channel(sql, now.Add(time.Minute)) {
+		t.Error("expected queries with different parameters to have their own channel")
+	}
+}
+
+func TestSubscribeStream_NotFound(t *testing.T) {
+	ds := plugin.NewDatasourceInstance(client.Use(&mock), &config)
+
+	subscription, err := ds.SubscribeStream(context.Background(), &backend.SubscribeStreamRequest{Path: "live/unknown"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if subscription.Status != backend.SubscribeStreamStatusNotFound {
+		t.Errorf("expected subscription to be not found, got %v", subscription.Status)
+	}
+}
+
+func TestPublishStream(t *testing.T) {
+	ds := plugin.NewDatasourceInstance(client.Use(&mock), &config)
+
+	res, err := ds.PublishStream(context.Background(), &backend.PublishStreamRequest{Path: "live/unknown"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.Status != backend.PublishStreamStatusPermissionDenied {
+		t.Errorf("expected publishing to be denied, got %v", res.Status)
+	}
+}
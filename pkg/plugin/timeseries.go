@@ -0,0 +1,110 @@
+package plugin
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// timeColumnName is the name of the column preferred as the time index of a time series.
+const timeColumnName = "time"
+
+var errNoTimeColumn = errors.New("time series format requires a datetime column, e.g. `SELECT time, value FROM ...`")
+
+// toTimeSeries converts a frame into a time series frame. The time column is
+// moved to the front and the rows are sorted by it; long frames (frames with
+// string or bool columns, such as `GROUP BY host`) are converted to wide frames
+// with one series per unique set of labels.
+func toTimeSeries(frame *data.Frame) (*data.Frame, error) {
+	if len(frame.Fields) == 0 {
+		return frame, nil
+	}
+
+	timeIdx := timeFieldIndex(frame)
+	if timeIdx < 0 {
+		return nil, errNoTimeColumn
+	}
+
+	frame = sortByTime(frame, timeIdx)
+
+	if frame.Rows() == 0 {
+		return frame, nil
+	}
+
+	if frame.TimeSeriesSchema().Type != data.TimeSeriesTypeLong {
+		return frame, nil
+	}
+
+	return data.LongToWide(frame, nil)
+}
+
+// timeFieldIndex returns the index of the field to use as the time index,
+// preferring a field named `time`, or -1 if the frame has no time field.
+func timeFieldIndex(frame *data.Frame) int {
+	indices := frame.TypeIndices(data.FieldTypeTime, data.FieldTypeNullableTime)
+	if len(indices) == 0 {
+		return -1
+	}
+
+	for _, idx := range indices {
+		if frame.Fields[idx].Name == timeColumnName {
+			return idx
+		}
+	}
+
+	return indices[0]
+}
+
+// sortByTime returns a copy of the frame with the time field first, rows
+// sorted in ascending time order and rows without a time dropped.
+func sortByTime(frame *data.Frame, timeIdx int) *data.Frame {
+	timeField := frame.Fields[timeIdx]
+
+	rows := make([]int, 0, timeField.Len())
+	times := make([]time.Time, timeField.Len())
+
+	for i := 0; i < timeField.Len(); i++ {
+		v, ok := timeField.ConcreteAt(i)
+		if !ok {
+			continue
+		}
+		times[i] = v.(time.Time)
+		rows = append(rows, i)
+	}
+
+	sort.SliceStable(rows, func(a, b int) bool {
+		return times[rows[a]].Before(times[rows[b]])
+	})
+
+	fields := make([]*data.Field, 0, len(frame.Fields))
+	fields = append(fields, data.NewField(timeField.Name, timeField.Labels, make([]time.Time, 0, len(rows))))
+
+	for i, field := range frame.Fields {
+		if i == timeIdx {
+			continue
+		}
+		fields = append(fields, data.NewFieldFromFieldType(field.Type(), 0))
+		fields[len(fields)-1].Name = field.Name
+		fields[len(fields)-1].Labels = field.Labels
+		fields[len(fields)-1].Config = field.Config
+	}
+
+	sorted := data.NewFrame(frame.Name, fields...)
+	sorted.Meta = frame.Meta
+
+	for _, row := range rows {
+		vals := make([]interface{}, 0, len(frame.Fields))
+		vals = append(vals, times[row])
+		for i, field := range frame.Fields {
+			if i == timeIdx {
+				continue
+			}
+			vals = append(vals, field.CopyAt(row))
+		}
+		sorted.AppendRow(vals...)
+	}
+
+	return sorted
+}
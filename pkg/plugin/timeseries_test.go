@@ -0,0 +1,99 @@
+package plugin_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana-labs/surrealdb-datasource/internal/mocks"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/plugin"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestCreateDataResponse_TimeSeriesLongToWide(t *testing.T) {
+	query := backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"rawSql": "SELECT time, host, cpu FROM metrics", "format": "time_series"}`),
+	}
+
+	successMock := mocks.MockSurrealDBClient{
+		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+			return []interface{}{
+				map[string]interface{}{
+					"status": "OK",
+					"result": []interface{}{
+						map[string]interface{}{"time": "2023-11-27T22:31:00Z", "host": "a", "cpu": 3},
+						map[string]interface{}{"time": "2023-11-27T22:30:00Z", "host": "a", "cpu": 1},
+						map[string]interface{}{"time": "2023-11-27T22:30:00Z", "host": "b", "cpu": 2},
+						map[string]interface{}{"time": "2023-11-27T22:31:00Z", "host": "b", "cpu": 4},
+					},
+					"time": "10ms",
+				},
+			}, nil
+		},
+	}
+
+	ds := plugin.NewDatasourceInstance(client.Use(&successMock), &config)
+
+	response := ds.CreateDataResponse(context.TODO(), query)
+
+	if response.Error != nil {
+		t.Fatalf("unexpected error: %s", response.Error)
+	}
+
+	frame := response.Frames[0]
+
+	if len(frame.Fields) != 3 {
+		t.Fatalf("expected 3 fields, got %d", len(frame.Fields))
+	}
+	if frame.Fields[0].Name != "time" || frame.Fields[0].Type() != data.FieldTypeTime {
+		t.Errorf("expected first field to be the time field, got %v (%s)", frame.Fields[0].Name, frame.Fields[0].Type())
+	}
+	if frame.Rows() != 2 {
+		t.Errorf("expected 2 rows, got %d", frame.Rows())
+	}
+	if first := frame.Fields[0].At(0).(time.Time); !first.Equal(time.Date(2023, 11, 27, 22, 30, 0, 0, time.UTC)) {
+		t.Errorf("expected rows to be sorted by time, got %v first", first)
+	}
+
+	for i, host := range []string{"a", "b"} {
+		field := frame.Fields[i+1]
+		if field.Labels["host"] != host {
+			t.Errorf("expected field %d to have label host=%s, got %v", i+1, host, field.Labels)
+		}
+	}
+}
+
+func TestCreateDataResponse_TimeSeriesWithoutTime(t *testing.T) {
+	query := backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"rawSql": "SELECT host, cpu FROM metrics", "format": "time_series"}`),
+	}
+
+	successMock := mocks.MockSurrealDBClient{
+		QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+			return []interface{}{
+				map[string]interface{}{
+					"status": "OK",
+					"result": []interface{}{
+						map[string]interface{}{"host": "a", "cpu": 1},
+					},
+					"time": "10ms",
+				},
+			}, nil
+		},
+	}
+
+	ds := plugin.NewDatasourceInstance(client.Use(&successMock), &config)
+
+	response := ds.CreateDataResponse(context.TODO(), query)
+
+	if response.Error == nil {
+		t.Error("expected error, got nil")
+	}
+	if response.Status != backend.StatusBadRequest {
+		t.Errorf("expected status bad request, got %v", response.Status)
+	}
+}
@@ -0,0 +1,102 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const (
+	// textColumnName is the name of the column holding the text of variable values.
+	textColumnName = "text"
+
+	// variableTextColumnName is the conventional name of a column holding the text of variable values.
+	variableTextColumnName = "__text"
+	// variableValueColumnName is the conventional name of a column holding variable values.
+	variableValueColumnName = "__value"
+)
+
+// toVariableFrame converts the frames of a variable query into a single frame
+// with the `text` and `value` fields expected by dashboard variables. The
+// `__text` and `__value` columns are used when present, otherwise the values
+// come from the `value` column, or the first column, and the texts from the
+// first other column, if any.
+func toVariableFrame(frames data.Frames) (*data.Frame, error) {
+	frame := data.NewFrame("variable",
+		data.NewField(textColumnName, nil, []string{}),
+		data.NewField(valueColumnName, nil, []string{}),
+	)
+
+	if len(frames) == 0 || len(frames[0].Fields) == 0 {
+		return frame, nil
+	}
+
+	if len(frames) > 1 {
+		return nil, fmt.Errorf("variable queries must return a single result, got %d", len(frames))
+	}
+
+	textField, valueField := variableFields(frames[0])
+
+	for i := 0; i < valueField.Len(); i++ {
+		value := fieldString(valueField, i)
+		text := fieldString(textField, i)
+
+		frame.AppendRow(text, value)
+	}
+
+	return frame, nil
+}
+
+// variableFields returns the fields holding the texts and values of variables.
+func variableFields(frame *data.Frame) (text *data.Field, value *data.Field) {
+	text, _ = frame.FieldByName(variableTextColumnName)
+	value, _ = frame.FieldByName(variableValueColumnName)
+
+	switch {
+	case text != nil && value != nil:
+		return text, value
+	case text != nil:
+		return text, text
+	case value != nil:
+		return value, value
+	}
+
+	value = frame.Fields[0]
+	if v, _ := frame.FieldByName(valueColumnName); v != nil {
+		value = v
+	}
+
+	text = value
+	for _, field := range frame.Fields {
+		if field != value {
+			text = field
+			break
+		}
+	}
+
+	return text, value
+}
+
+// fieldString returns the value of a field at idx as a string.
+func fieldString(field *data.Field, idx int) string {
+	v, ok := field.ConcreteAt(idx)
+	if !ok {
+		return ""
+	}
+
+	switch v := v.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.RawMessage:
+		return string(v)
+	}
+
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,109 @@
+package plugin_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafana-labs/surrealdb-datasource/internal/mocks"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/client"
+	"github.com/grafana-labs/surrealdb-datasource/pkg/plugin"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestCreateDataResponse_Variable(t *testing.T) {
+	cases := []struct {
+		name   string
+		result interface{}
+		texts  []string
+		values []string
+	}{
+		{
+			name:   "SELECT VALUE",
+			result: []interface{}{"a", "b"},
+			texts:  []string{"a", "b"},
+			values: []string{"a", "b"},
+		},
+		{
+			name:   "SELECT VALUE numbers",
+			result: []interface{}{1, 2.5},
+			texts:  []string{"1", "2.5"},
+			values: []string{"1", "2.5"},
+		},
+		{
+			name: "__text and __value columns",
+			result: []interface{}{
+				map[string]interface{}{"__text": "Host A", "__value": "host:a", "other": 1},
+				map[string]interface{}{"__text": "Host B", "__value": "host:b", "other": 2},
+			},
+			texts:  []string{"Host A", "Host B"},
+			values: []string{"host:a", "host:b"},
+		},
+		{
+			name: "__value column only",
+			result: []interface{}{
+				map[string]interface{}{"__value": "host:a", "other": 1},
+			},
+			texts:  []string{"host:a"},
+			values: []string{"host:a"},
+		},
+		{
+			name: "two columns",
+			result: []interface{}{
+				map[string]interface{}{"id": "host:a", "name": "Host A"},
+			},
+			texts:  []string{"Host A"},
+			values: []string{"host:a"},
+		},
+		{
+			name:   "no results",
+			result: []interface{}{},
+			texts:  []string{},
+			values: []string{},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			variableMock := mocks.MockSurrealDBClient{
+				QueryFunc: func(sql string, vars interface{}) (interface{}, error) {
+					return []interface{}{
+						map[string]interface{}{"status": "OK", "result": tt.result, "time": "1ms"},
+					}, nil
+				},
+			}
+
+			ds := plugin.NewDatasourceInstance(client.Use(&variableMock), &config)
+
+			response := ds.CreateDataResponse(context.TODO(), backend.DataQuery{
+				RefID:     "A",
+				QueryType: plugin.QueryTypeVariable,
+				JSON:      []byte(`{"rawSql": "SELECT VALUE name FROM host"}`),
+			})
+
+			if response.Error != nil {
+				t.Fatalf("unexpected error: %s", response.Error)
+			}
+			if len(response.Frames) != 1 {
+				t.Fatalf("expected 1 frame, got %d", len(response.Frames))
+			}
+
+			frame := response.Frames[0]
+
+			if frame.Fields[0].Name != "text" || frame.Fields[1].Name != "value" {
+				t.Fatalf("expected text and value fields, got %s and %s", frame.Fields[0].Name, frame.Fields[1].Name)
+			}
+			if frame.Rows() != len(tt.values) {
+				t.Fatalf("expected %d rows, got %d", len(tt.values), frame.Rows())
+			}
+
+			for i := range tt.values {
+				if text := frame.Fields[0].At(i); text != tt.texts[i] {
+					t.Errorf("expected text %q, got %q", tt.texts[i], text)
+				}
+				if value := frame.Fields[1].At(i); value != tt.values[i] {
+					t.Errorf("expected value %q, got %q", tt.values[i], value)
+				}
+			}
+		})
+	}
+}
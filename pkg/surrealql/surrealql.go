@@ -0,0 +1,483 @@
+// Package surrealql provides a minimal lexer for SurrealQL, which is enough to
+// split queries into statements, to edit the clauses of SELECT statements and
+// to read the types of field definitions without being fooled by strings,
+// comments or subqueries.
+package surrealql
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenKind is the kind of a token.
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenString
+	tokenSymbol
+)
+
+// token is a lexical token of a query, with its position in the query and its
+// nesting depth in parentheses, brackets and braces.
+type token struct {
+	kind  tokenKind
+	text  string
+	start int
+	end   int
+	depth int
+}
+
+// selectClauses are the clauses which follow the WHERE clause of a SELECT statement.
+var selectClauses = map[string]bool{
+	"SPLIT":    true,
+	"GROUP":    true,
+	"ORDER":    true,
+	"LIMIT":    true,
+	"START":    true,
+	"FETCH":    true,
+	"TIMEOUT":  true,
+	"PARALLEL": true,
+	"EXPLAIN":  true,
+}
+
+// readOnlyStatements are the statements which do not change data.
+var readOnlyStatements = map[string]bool{
+	"SELECT": true,
+	"INFO":   true,
+	"LET":    true,
+	"RETURN": true,
+	"BEGIN":  true,
+	"CANCEL": true,
+	"COMMIT": true,
+}
+
+// writeKeywords are the keywords of statements changing data or the schema,
+// which can also appear in subqueries.
+var writeKeywords = map[string]bool{
+	"CREATE":  true,
+	"UPDATE":  true,
+	"UPSERT":  true,
+	"DELETE":  true,
+	"RELATE":  true,
+	"INSERT":  true,
+	"DEFINE":  true,
+	"REMOVE":  true,
+	"ALTER":   true,
+	"REBUILD": true,
+	"LIVE":    true,
+	"KILL":    true,
+}
+
+// fieldClauses are the clauses which can follow the type of a field definition.
+var fieldClauses = map[string]bool{
+	"ASSERT":      true,
+	"COMMENT":     true,
+	"DEFAULT":     true,
+	"FLEXIBLE":    true,
+	"PERMISSIONS": true,
+	"READONLY":    true,
+	"REFERENCE":   true,
+	"VALUE":       true,
+}
+
+// sideEffectPackages are the function packages which may have side effects:
+// HTTP requests and custom functions.
+var sideEffectPackages = map[string]bool{
+	"HTTP": true,
+	"FN":   true,
+}
+
+// Split splits a query into its statements, without their trailing semicolon.
+// Empty statements are dropped.
+func Split(sql string) []string {
+	var statements []string
+
+	start := 0
+	for _, t := range tokenize(sql) {
+		if t.kind == tokenSymbol && t.text == ";" && t.depth == 0 {
+			statements = appendStatement(statements, sql[start:t.start])
+			start = t.end
+		}
+	}
+
+	return appendStatement(statements, sql[start:])
+}
+
+// appendStatement appends stmt to statements unless it is empty.
+func appendStatement(statements []string, stmt string) []string {
+	if len(tokenize(stmt)) == 0 {
+		return statements
+	}
+
+	return append(statements, strings.TrimSpace(stmt))
+}
+
+// ReadOnly reports whether a query only reads data, i.e. it can safely run
+// again. Queries are considered read-only when all their statements are read
+// statements, e.g. SELECT or INFO, without subqueries changing data or calls to
+// functions with side effects. Field names which are also keywords, e.g.
+// `SELECT update FROM ...`, make queries look like they write data.
+func ReadOnly(sql string) bool {
+	statements := Split(sql)
+	if len(statements) == 0 {
+		return false
+	}
+
+	for _, stmt := range statements {
+		tokens := tokenize(stmt)
+		if !readOnlyStatements[strings.ToUpper(tokens[0].text)] || !isKeyword(tokens, 0, tokens[0].text) {
+			return false
+		}
+
+		for i, t := range tokens {
+			if t.kind != tokenWord || !isWord(tokens, i) {
+				continue
+			}
+
+			word := strings.ToUpper(t.text)
+			if writeKeywords[word] {
+				return false
+			}
+			if sideEffectPackages[word] && i+1 < len(tokens) && tokens[i+1].text == ":" {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// AddCondition adds a condition to the WHERE clause of a SELECT or LIVE SELECT
+// statement, creating the clause if needed. An existing condition is kept and
+// combined with AND. The statement is returned as is, with false, when it is
+// not a SELECT statement.
+//
+//	SELECT * FROM host LIMIT 10 => SELECT * FROM host WHERE <cond> LIMIT 10
+//	SELECT * FROM host WHERE a OR b => SELECT * FROM host WHERE (a OR b) AND <cond>
+func AddCondition(stmt string, cond string) (string, bool) {
+	tokens := tokenize(stmt)
+
+	first := 0
+	if len(tokens) > 0 && isKeyword(tokens, 0, "LIVE") {
+		first = 1
+	}
+	if len(tokens) <= first || !isKeyword(tokens, first, "SELECT") {
+		return stmt, false
+	}
+
+	from := -1
+	for i := first + 1; i < len(tokens) && from < 0; i++ {
+		if isKeyword(tokens, i, "FROM") {
+			from = i
+		}
+	}
+	if from < 0 {
+		return stmt, false
+	}
+
+	where := -1
+	end := tokens[len(tokens)-1].end
+	for i := from + 1; i < len(tokens); i++ {
+		if where < 0 && isKeyword(tokens, i, "WHERE") {
+			where = i
+			continue
+		}
+		if isClause(tokens, i) {
+			end = tokens[i].start
+			break
+		}
+	}
+
+	rest := stmt[end:]
+	if r, _ := utf8.DecodeRuneInString(rest); rest != "" && !unicode.IsSpace(r) {
+		rest = " " + rest
+	}
+
+	if where < 0 {
+		return strings.TrimRight(stmt[:end], " \t\r\n") + " WHERE " + cond + rest, true
+	}
+
+	existing := strings.TrimSpace(stmt[tokens[where].end:end])
+	if existing == "" {
+		return stmt[:tokens[where].end] + " " + cond + rest, true
+	}
+
+	return stmt[:tokens[where].end] + " (" + existing + ") AND " + cond + rest, true
+}
+
+// WithTimeout adds a TIMEOUT clause to the SELECT statements of a query which
+// do not have one already, so the server stops working on them after d. Live
+// queries, which cannot time out, are left as is.
+//
+//	SELECT * FROM host PARALLEL => SELECT * FROM host TIMEOUT 1500ms PARALLEL
+func WithTimeout(sql string, d time.Duration) string {
+	clause := fmt.Sprintf("TIMEOUT %dms", max(d.Milliseconds(), 1))
+
+	return addClause(sql, clause, []string{"TIMEOUT"}, []string{"PARALLEL", "EXPLAIN"})
+}
+
+// WithLimit adds a LIMIT clause to the SELECT statements of a query which do not
+// have one already, so the server returns at most n rows per statement. Live
+// queries and statements selecting a single record with ONLY are left as is.
+//
+//	SELECT * FROM host FETCH metrics => SELECT * FROM host LIMIT 1000 FETCH metrics
+func WithLimit(sql string, n int) string {
+	clause := fmt.Sprintf("LIMIT %d", n)
+
+	return addClause(sql, clause, []string{"LIMIT", "ONLY"}, []string{"START", "FETCH", "TIMEOUT", "PARALLEL", "EXPLAIN"})
+}
+
+// addClause adds a clause to the SELECT statements of a query, before the first
+// of the following clauses or at the end of the statement. Statements with any
+// of the skip keywords before that position are left as is.
+func addClause(sql string, clause string, skip []string, following []string) string {
+	statements := Split(sql)
+	changed := false
+
+	for i, stmt := range statements {
+		tokens := tokenize(stmt)
+		if !isKeyword(tokens, 0, "SELECT") {
+			continue
+		}
+
+		end := tokens[len(tokens)-1].end
+		skipped := false
+		for j := range tokens {
+			if isAnyKeyword(tokens, j, skip...) {
+				skipped = true
+				break
+			}
+			if isAnyKeyword(tokens, j, following...) {
+				end = tokens[j].start
+				break
+			}
+		}
+		if skipped {
+			continue
+		}
+
+		rest := stmt[end:]
+		if r, _ := utf8.DecodeRuneInString(rest); rest != "" && !unicode.IsSpace(r) {
+			rest = " " + rest
+		}
+
+		statements[i] = strings.TrimRight(stmt[:end], " \t\r\n") + " " + clause + rest
+		changed = true
+	}
+
+	if !changed {
+		return sql
+	}
+
+	return strings.Join(statements, ";\n")
+}
+
+// FieldType returns the type of a DEFINE FIELD statement, e.g. `option<int>` or
+// `array<string, 10>`, or "" when it has none. The TYPE clause is looked for
+// after the table of the field, so that fields or tables named `type` are not
+// taken for it.
+//
+//	DEFINE FIELD type ON event TYPE string DEFAULT 'info' => string
+func FieldType(definition string) string {
+	tokens := tokenize(definition)
+	if len(tokens) < 2 || !isKeyword(tokens, 0, "DEFINE") || !isKeyword(tokens, 1, "FIELD") {
+		return ""
+	}
+
+	// the name follows `OVERWRITE` or `IF NOT EXISTS`, if any
+	name := 2
+	switch {
+	case len(tokens) > 2 && isKeyword(tokens, 2, "OVERWRITE"):
+		name = 3
+	case len(tokens) > 2 && isKeyword(tokens, 2, "IF"):
+		name = 5
+	}
+
+	// the name of the field, e.g. `on` or `tags[*]`, is followed by `ON [TABLE] <table>`
+	table := -1
+	for i := name + 1; i < len(tokens) && table < 0; i++ {
+		if isKeyword(tokens, i, "ON") {
+			table = i + 1
+		}
+	}
+	if table < 0 || table >= len(tokens) {
+		return ""
+	}
+	if isKeyword(tokens, table, "TABLE") && table+1 < len(tokens) && !isFieldClause(tokens, table+1) && !isKeyword(tokens, table+1, "TYPE") {
+		table++
+	}
+
+	for i := table + 1; i < len(tokens); i++ {
+		if isKeyword(tokens, i, "TYPE") && (i+1 >= len(tokens) || tokens[i+1].text != ":") {
+			return typeText(definition, tokens[i+1:])
+		}
+	}
+
+	return ""
+}
+
+// typeText returns the text of the type starting at the first token, which ends
+// before the next clause of the field definition outside of angle brackets.
+func typeText(definition string, tokens []token) string {
+	if len(tokens) == 0 {
+		return ""
+	}
+
+	end := len(definition)
+	angles := 0
+	for i, t := range tokens {
+		switch {
+		case t.kind == tokenSymbol && t.text == "<":
+			angles++
+		case t.kind == tokenSymbol && t.text == ">":
+			angles--
+		case t.kind == tokenSymbol && t.text == ";" && t.depth == 0:
+			end = t.start
+		case angles <= 0 && isFieldClause(tokens, i):
+			end = t.start
+		}
+		if end != len(definition) {
+			break
+		}
+	}
+
+	return strings.TrimSpace(definition[tokens[0].start:end])
+}
+
+// isFieldClause reports whether the token at idx starts a clause following the
+// type of a field definition.
+func isFieldClause(tokens []token, idx int) bool {
+	return fieldClauses[strings.ToUpper(tokens[idx].text)] && isKeyword(tokens, idx, tokens[idx].text)
+}
+
+// isAnyKeyword reports whether the token at idx is one of the keywords at the
+// top level of the statement.
+func isAnyKeyword(tokens []token, idx int, keywords ...string) bool {
+	for _, kw := range keywords {
+		if isKeyword(tokens, idx, kw) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isClause reports whether the token at idx starts a clause following the
+// WHERE clause of a SELECT statement.
+func isClause(tokens []token, idx int) bool {
+	return selectClauses[strings.ToUpper(tokens[idx].text)] && isKeyword(tokens, idx, tokens[idx].text)
+}
+
+// isKeyword reports whether the token at idx is the keyword kw at the top level
+// of the statement, as opposed to a parameter, a field or a function name,
+// e.g. `$limit`, `a.group` or `time::group`.
+func isKeyword(tokens []token, idx int, kw string) bool {
+	t := tokens[idx]
+	if t.kind != tokenWord || t.depth != 0 || !strings.EqualFold(t.text, kw) {
+		return false
+	}
+
+	return isWord(tokens, idx)
+}
+
+// isWord reports whether the word token at idx can be a keyword, at any depth,
+// i.e. it is not a parameter, a field or a function name.
+func isWord(tokens []token, idx int) bool {
+	if idx > 0 {
+		prev := tokens[idx-1]
+		t := tokens[idx]
+		if prev.kind == tokenSymbol && prev.end == t.start && strings.ContainsAny(prev.text, "$.:") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// tokenize splits a query into tokens, skipping whitespace and comments.
+func tokenize(sql string) []token {
+	var tokens []token
+
+	depth := 0
+	for i := 0; i < len(sql); {
+		r, size := utf8.DecodeRuneInString(sql[i:])
+
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case strings.HasPrefix(sql[i:], "--"), strings.HasPrefix(sql[i:], "//"), r == '#':
+			i = skipUntil(sql, i, "\n")
+		case strings.HasPrefix(sql[i:], "/*"):
+			i = skipUntil(sql, i+1, "*/")
+		case r == '\'' || r == '"':
+			end := skipQuoted(sql, i, r)
+			tokens = append(tokens, token{kind: tokenString, text: sql[i:end], start: i, end: end, depth: depth})
+			i = end
+		case r == '`':
+			end := skipQuoted(sql, i, r)
+			tokens = append(tokens, token{kind: tokenWord, text: sql[i:end], start: i, end: end, depth: depth})
+			i = end
+		case r == '⟨':
+			end := skipUntil(sql, i, "⟩")
+			tokens = append(tokens, token{kind: tokenWord, text: sql[i:end], start: i, end: end, depth: depth})
+			i = end
+		case isWordRune(r):
+			end := i
+			for end < len(sql) {
+				r, size := utf8.DecodeRuneInString(sql[end:])
+				if !isWordRune(r) {
+					break
+				}
+				end += size
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: sql[i:end], start: i, end: end, depth: depth})
+			i = end
+		default:
+			if strings.ContainsRune(")]}", r) && depth > 0 {
+				depth--
+			}
+			tokens = append(tokens, token{kind: tokenSymbol, text: sql[i : i+size], start: i, end: i + size, depth: depth})
+			if strings.ContainsRune("([{", r) {
+				depth++
+			}
+			i += size
+		}
+	}
+
+	return tokens
+}
+
+// isWordRune reports whether r can be part of an identifier, a keyword or a number.
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// skipUntil returns the position following the first occurrence of delim after
+// the position i, or the end of sql.
+func skipUntil(sql string, i int, delim string) int {
+	_, size := utf8.DecodeRuneInString(sql[i:])
+	if idx := strings.Index(sql[i+size:], delim); idx >= 0 {
+		return i + size + idx + len(delim)
+	}
+
+	return len(sql)
+}
+
+// skipQuoted returns the position following the string or identifier quoted
+// with quote starting at the position i, honoring backslash escapes.
+func skipQuoted(sql string, i int, quote rune) int {
+	for j := i + 1; j < len(sql); j++ {
+		switch rune(sql[j]) {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		}
+	}
+
+	return len(sql)
+}
@@ -0,0 +1,305 @@
+package surrealql_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/grafana-labs/surrealdb-datasource/pkg/surrealql"
+)
+
+func TestSplit(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "single statement",
+			input:    "SELECT * FROM host",
+			expected: []string{"SELECT * FROM host"},
+		},
+		{
+			name:     "several statements",
+			input:    "SELECT * FROM host;\n SELECT * FROM metrics; ",
+			expected: []string{"SELECT * FROM host", "SELECT * FROM metrics"},
+		},
+		{
+			name:     "semicolons in strings, comments and blocks",
+			input:    "SELECT * FROM host WHERE name = 'a;b' -- c;d\n; IF true { RETURN 1; }",
+			expected: []string{"SELECT * FROM host WHERE name = 'a;b' -- c;d", "IF true { RETURN 1; }"},
+		},
+		{
+			name:     "empty statements",
+			input:    "; /* nothing */ ;",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if statements := surrealql.Split(tt.input); !reflect.DeepEqual(statements, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, statements)
+			}
+		})
+	}
+}
+
+func TestAddCondition(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		expected string
+		ok       bool
+	}{
+		{
+			name:     "without WHERE",
+			input:    "SELECT * FROM host",
+			expected: "SELECT * FROM host WHERE x = 1",
+			ok:       true,
+		},
+		{
+			name:     "with WHERE",
+			input:    "SELECT * FROM host WHERE a = 1 OR b = 2",
+			expected: "SELECT * FROM host WHERE (a = 1 OR b = 2) AND x = 1",
+			ok:       true,
+		},
+		{
+			name:     "before clauses",
+			input:    "SELECT count() AS total, time::group(ts, 'hour') AS time FROM metrics WHERE $limit > 0 GROUP BY time ORDER BY time LIMIT 10",
+			expected: "SELECT count() AS total, time::group(ts, 'hour') AS time FROM metrics WHERE ($limit > 0) AND x = 1 GROUP BY time ORDER BY time LIMIT 10",
+			ok:       true,
+		},
+		{
+			name:     "subqueries",
+			input:    "SELECT *, (SELECT * FROM metrics WHERE host = $parent.id LIMIT 1) AS last FROM host LIMIT 5",
+			expected: "SELECT *, (SELECT * FROM metrics WHERE host = $parent.id LIMIT 1) AS last FROM host WHERE x = 1 LIMIT 5",
+			ok:       true,
+		},
+		{
+			name:     "keywords in strings and comments",
+			input:    "SELECT * FROM host WHERE name = 'LIMIT' -- ORDER BY name",
+			expected: "SELECT * FROM host WHERE (name = 'LIMIT') AND x = 1 -- ORDER BY name",
+			ok:       true,
+		},
+		{
+			name:     "live select",
+			input:    "live select * from host fetch metrics",
+			expected: "live select * from host WHERE x = 1 fetch metrics",
+			ok:       true,
+		},
+		{
+			name:     "not a select",
+			input:    "INFO FOR DB",
+			expected: "INFO FOR DB",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt, ok := surrealql.AddCondition(tt.input, "x = 1")
+
+			if ok != tt.ok {
+				t.Errorf("expected %v, got %v", tt.ok, ok)
+			}
+			if stmt != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, stmt)
+			}
+		})
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected bool
+	}{
+		{input: "SELECT * FROM host", expected: true},
+		{input: "INFO FOR DB; SELECT count() FROM host GROUP ALL", expected: true},
+		{input: "LET $t = time::now(); SELECT * FROM metrics WHERE time > $t - 1h", expected: true},
+		{input: "BEGIN TRANSACTION; CANCEL TRANSACTION;", expected: true},
+		{input: "SELECT * FROM host WHERE name = 'DELETE'", expected: true},
+		{input: "SELECT * FROM host WHERE $update", expected: true},
+		{input: "CREATE host SET name = 'a'", expected: false},
+		{input: "SELECT * FROM host; DELETE host", expected: false},
+		{input: "SELECT * FROM (UPDATE host SET seen = true)", expected: false},
+		{input: "LIVE SELECT * FROM host", expected: false},
+		{input: "RETURN http::post('https://example.com')", expected: false},
+		{input: "SELECT fn::cleanup() FROM host", expected: false},
+		{input: "", expected: false},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.input, func(t *testing.T) {
+			if readOnly := surrealql.ReadOnly(tt.input); readOnly != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, readOnly)
+			}
+		})
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "select",
+			input:    "SELECT * FROM host LIMIT 10",
+			expected: "SELECT * FROM host LIMIT 10 TIMEOUT 1500ms",
+		},
+		{
+			name:     "before PARALLEL and EXPLAIN",
+			input:    "SELECT * FROM host WHERE name = 'PARALLEL' PARALLEL EXPLAIN FULL",
+			expected: "SELECT * FROM host WHERE name = 'PARALLEL' TIMEOUT 1500ms PARALLEL EXPLAIN FULL",
+		},
+		{
+			name:     "existing timeout",
+			input:    "SELECT * FROM host TIMEOUT 5s",
+			expected: "SELECT * FROM host TIMEOUT 5s",
+		},
+		{
+			name:     "several statements",
+			input:    "LET $h = 'a'; SELECT * FROM host WHERE name = $h; SELECT * FROM (SELECT * FROM metrics)",
+			expected: "LET $h = 'a';\nSELECT * FROM host WHERE name = $h TIMEOUT 1500ms;\nSELECT * FROM (SELECT * FROM metrics) TIMEOUT 1500ms",
+		},
+		{
+			name:     "live select",
+			input:    "LIVE SELECT * FROM host",
+			expected: "LIVE SELECT * FROM host",
+		},
+		{
+			name:     "trailing comment",
+			input:    "SELECT * FROM host -- all hosts",
+			expected: "SELECT * FROM host TIMEOUT 1500ms -- all hosts",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if sql := surrealql.WithTimeout(tt.input, 1500*time.Millisecond); sql != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, sql)
+			}
+		})
+	}
+}
+
+func TestWithLimit(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "select",
+			input:    "SELECT * FROM host",
+			expected: "SELECT * FROM host LIMIT 100",
+		},
+		{
+			name:     "before START and FETCH",
+			input:    "SELECT * FROM host ORDER BY name START 10 FETCH metrics",
+			expected: "SELECT * FROM host ORDER BY name LIMIT 100 START 10 FETCH metrics",
+		},
+		{
+			name:     "before TIMEOUT",
+			input:    "SELECT * FROM host TIMEOUT 5s",
+			expected: "SELECT * FROM host LIMIT 100 TIMEOUT 5s",
+		},
+		{
+			name:     "existing limit",
+			input:    "SELECT * FROM host LIMIT 10",
+			expected: "SELECT * FROM host LIMIT 10",
+		},
+		{
+			name:     "subqueries",
+			input:    "SELECT *, (SELECT * FROM metrics LIMIT 1) AS last FROM host",
+			expected: "SELECT *, (SELECT * FROM metrics LIMIT 1) AS last FROM host LIMIT 100",
+		},
+		{
+			name:     "single record",
+			input:    "SELECT * FROM ONLY host:web",
+			expected: "SELECT * FROM ONLY host:web",
+		},
+		{
+			name:     "live select",
+			input:    "LIVE SELECT * FROM host",
+			expected: "LIVE SELECT * FROM host",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if sql := surrealql.WithLimit(tt.input, 100); sql != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, sql)
+			}
+		})
+	}
+}
+
+func TestFieldType(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "simple type",
+			input:    "DEFINE FIELD name ON person TYPE string",
+			expected: "string",
+		},
+		{
+			name:     "followed by clauses",
+			input:    "DEFINE FIELD age ON TABLE person TYPE option<int> PERMISSIONS FULL",
+			expected: "option<int>",
+		},
+		{
+			name:     "with spaces",
+			input:    "DEFINE FIELD tags ON person TYPE array<string, 10> DEFAULT []",
+			expected: "array<string, 10>",
+		},
+		{
+			name:     "union",
+			input:    "DEFINE FIELD rank ON person TYPE int | float ASSERT $value >= 0",
+			expected: "int | float",
+		},
+		{
+			name:     "field named type",
+			input:    "DEFINE FIELD type ON events TYPE string",
+			expected: "string",
+		},
+		{
+			name:     "table named type",
+			input:    "DEFINE FIELD count ON type TYPE int",
+			expected: "int",
+		},
+		{
+			name:     "table named table",
+			input:    "DEFINE FIELD count ON table TYPE int",
+			expected: "int",
+		},
+		{
+			name:     "overwrite",
+			input:    "DEFINE FIELD OVERWRITE on ON person FLEXIBLE TYPE object",
+			expected: "object",
+		},
+		{
+			name:     "function in the default value",
+			input:    "DEFINE FIELD kind ON person DEFAULT type::string(1)",
+			expected: "",
+		},
+		{
+			name:     "no type",
+			input:    "DEFINE FIELD name ON person PERMISSIONS FULL",
+			expected: "",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if typ := surrealql.FieldType(tt.input); typ != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, typ)
+			}
+		})
+	}
+}
@@ -1,15 +1,84 @@
-import React from 'react';
-import { CodeEditor } from '@grafana/ui';
+import React, { useEffect, useState } from 'react';
+import {
+  CodeEditor,
+  CodeEditorSuggestionItem,
+  CodeEditorSuggestionItemKind,
+  InlineField,
+  InlineSwitch,
+  Input,
+  RadioButtonGroup,
+  Stack,
+} from '@grafana/ui';
 import { DataSource } from '../datasource';
 import type { QueryEditorProps } from '@grafana/data';
-import type { SurrealDataSourceOptions, SurrealQuery } from '../types';
+import type { ArrayMode, QueryFormat, SurrealDataSourceOptions, SurrealQuery } from '../types';
+
+const formatOptions: Array<{ label: string; value: QueryFormat }> = [
+  { label: 'Table', value: 'table' },
+  { label: 'Time series', value: 'time_series' },
+  { label: 'Graph', value: 'graph' },
+];
+
+const arrayModeOptions: Array<{ label: string; value: ArrayMode; description: string }> = [
+  { label: 'JSON', value: 'json', description: 'Keep arrays as JSON' },
+  { label: 'Join', value: 'join', description: 'Join the elements of arrays with commas' },
+  { label: 'Explode', value: 'explode', description: 'Return a row per element of arrays' },
+];
 
 type Props = QueryEditorProps<DataSource, SurrealQuery, SurrealDataSourceOptions>;
 
-export function QueryEditor({ query, onChange }: Props) {
+export function QueryEditor({ datasource, query, onChange }: Props) {
+  const [suggestions, setSuggestions] = useState<CodeEditorSuggestionItem[]>([]);
+
+  // load the tables and their fields for autocompletion
+  useEffect(() => {
+    let cancelled = false;
+
+    const loadSuggestions = async () => {
+      const tables = await datasource.getTables();
+      const fields = await Promise.all(tables.map((table) => datasource.getFields(table).catch(() => [])));
+
+      if (cancelled) {
+        return;
+      }
+
+      setSuggestions([
+        ...tables.map((table) => ({ label: table, kind: CodeEditorSuggestionItemKind.Text, detail: 'table' })),
+        ...fields.flatMap((tableFields, i) =>
+          tableFields.map((field) => ({
+            label: field.name,
+            kind: CodeEditorSuggestionItemKind.Property,
+            detail: `${tables[i]}${field.type ? ` (${field.type})` : ''}`,
+          }))
+        ),
+      ]);
+    };
+
+    loadSuggestions().catch(() => setSuggestions([]));
+
+    return () => {
+      cancelled = true;
+    };
+  }, [datasource]);
+
   const onQueryChange = (rawSql: string) => onChange({ ...query, rawSql });
+  const onFormatChange = (format: QueryFormat) => onChange({ ...query, format });
+  const onLiveChange = (event: React.FormEvent<HTMLInputElement>) =>
+    onChange({ ...query, live: event.currentTarget.checked });
+  const onArrayModeChange = (arrayMode: ArrayMode) => onChange({ ...query, arrayMode });
+  const onSplitRecordIdsChange = (event: React.FormEvent<HTMLInputElement>) =>
+    onChange({ ...query, splitRecordIds: event.currentTarget.checked });
+  const onKeepEmptyResultsChange = (event: React.FormEvent<HTMLInputElement>) =>
+    onChange({ ...query, keepEmptyResults: event.currentTarget.checked });
+  const onTextChange =
+    (key: 'nodeTitle' | 'nodeSubtitle' | 'nodeMainStat') => (event: React.FormEvent<HTMLInputElement>) =>
+      onChange({ ...query, [key]: event.currentTarget.value || undefined });
+  const onNumberChange =
+    (key: 'queryTimeout' | 'maxRows' | 'flattenDepth') => (event: React.FormEvent<HTMLInputElement>) =>
+      onChange({ ...query, [key]: event.currentTarget.value ? parseInt(event.currentTarget.value, 10) : undefined });
 
-  const { rawSql } = query;
+  const { rawSql, format, live, queryTimeout, maxRows, flattenDepth, arrayMode, splitRecordIds } = query;
+  const { keepEmptyResults, nodeTitle, nodeSubtitle, nodeMainStat } = query;
 
   return (
     <>
@@ -20,7 +89,77 @@ export function QueryEditor({ query, onChange }: Props) {
         showMiniMap={false}
         showLineNumbers={true}
         height="240px"
+        getSuggestions={() => suggestions}
       />
+      <Stack direction="row">
+        <InlineField label="Format" labelWidth={12}>
+          <RadioButtonGroup options={formatOptions} value={format ?? 'table'} onChange={onFormatChange} />
+        </InlineField>
+        <InlineField label="Live" tooltip="Stream record changes using a LIVE SELECT">
+          <InlineSwitch value={live ?? false} onChange={onLiveChange} />
+        </InlineField>
+        <InlineField label="Timeout" tooltip="Seconds after which the query is aborted, if lower than the datasource">
+          <Input
+            type="number"
+            min={0}
+            width={10}
+            value={queryTimeout ?? ''}
+            onChange={onNumberChange('queryTimeout')}
+            aria-label="Timeout"
+          />
+        </InlineField>
+        <InlineField label="Max rows" tooltip="Rows returned per statement, if lower than the datasource max rows">
+          <Input
+            type="number"
+            min={0}
+            width={10}
+            value={maxRows ?? ''}
+            onChange={onNumberChange('maxRows')}
+            aria-label="Max rows"
+          />
+        </InlineField>
+      </Stack>
+      <Stack direction="row">
+        <InlineField
+          label="Flatten depth"
+          labelWidth={12}
+          tooltip="Levels of nested objects flattened into dotted columns"
+        >
+          <Input
+            type="number"
+            min={0}
+            width={10}
+            value={flattenDepth ?? ''}
+            onChange={onNumberChange('flattenDepth')}
+            aria-label="Flatten depth"
+          />
+        </InlineField>
+        <InlineField label="Arrays">
+          <RadioButtonGroup options={arrayModeOptions} value={arrayMode ?? 'json'} onChange={onArrayModeChange} />
+        </InlineField>
+        <InlineField label="Split record IDs" tooltip="Add the table and the id of record IDs as columns">
+          <InlineSwitch value={splitRecordIds ?? false} onChange={onSplitRecordIdsChange} />
+        </InlineField>
+        <InlineField
+          label="Keep empty results"
+          tooltip="Return an empty frame for the statements without rows, such as LET statements"
+        >
+          <InlineSwitch value={keepEmptyResults ?? false} onChange={onKeepEmptyResultsChange} />
+        </InlineField>
+      </Stack>
+      {format === 'graph' && (
+        <Stack direction="row">
+          <InlineField label="Node title" labelWidth={12} tooltip="Field of the records shown as the title of nodes">
+            <Input width={16} value={nodeTitle ?? ''} onChange={onTextChange('nodeTitle')} placeholder="id" />
+          </InlineField>
+          <InlineField label="Subtitle" tooltip="Field of the records shown as the subtitle of nodes">
+            <Input width={16} value={nodeSubtitle ?? ''} onChange={onTextChange('nodeSubtitle')} />
+          </InlineField>
+          <InlineField label="Main stat" tooltip="Field of the records shown in the middle of nodes">
+            <Input width={16} value={nodeMainStat ?? ''} onChange={onTextChange('nodeMainStat')} />
+          </InlineField>
+        </Stack>
+      )}
     </>
   );
 }
@@ -1,14 +1,121 @@
-import { DataSourceInstanceSettings, CoreApp } from '@grafana/data';
-import { DataSourceWithBackend } from '@grafana/runtime';
+import {
+  AdHocVariableFilter,
+  CoreApp,
+  DataQueryRequest,
+  DataQueryResponse,
+  DataSourceGetTagKeysOptions,
+  DataSourceGetTagValuesOptions,
+  DataSourceInstanceSettings,
+  MetricFindValue,
+  ScopedVars,
+} from '@grafana/data';
+import { DataSourceWithBackend, getTemplateSrv } from '@grafana/runtime';
+import { from, mergeMap, Observable } from 'rxjs';
 
-import { SurrealQuery, SurrealDataSourceOptions, DEFAULT_QUERY } from './types';
+import {
+  SurrealQuery,
+  SurrealDataSourceOptions,
+  SurrealField,
+  SurrealIndex,
+  TagKey,
+  TemplateVariable,
+  ValueType,
+  DEFAULT_QUERY,
+} from './types';
+import { SurrealVariableSupport } from './variables';
 
 export class DataSource extends DataSourceWithBackend<SurrealQuery, SurrealDataSourceOptions> {
+  /**
+   * The types of the values of the ad-hoc filter keys, loaded with the keys
+   */
+  private tagTypes?: Record<string, ValueType>;
+
   constructor(instanceSettings: DataSourceInstanceSettings<SurrealDataSourceOptions>) {
     super(instanceSettings);
+    this.variables = new SurrealVariableSupport();
+    this.annotations = {
+      prepareQuery: (anno) => (anno.target ? { ...anno.target, refId: 'Anno', queryType: 'annotation' } : undefined),
+    };
   }
 
   getDefaultQuery(_: CoreApp): Partial<SurrealQuery> {
     return DEFAULT_QUERY
   }
+
+  /**
+   * Leaves the query untouched and sends the values of the variables it
+   * references, which are formatted as SurrealQL literals by the backend,
+   * along with the ad-hoc filters
+   */
+  applyTemplateVariables(query: SurrealQuery, scopedVars: ScopedVars, filters?: AdHocVariableFilter[]): SurrealQuery {
+    const templateSrv = getTemplateSrv();
+    const variables: Record<string, TemplateVariable> = {};
+
+    for (const variable of templateSrv.getVariables()) {
+      const name = variable.name;
+      if (!new RegExp(`\\$(\\{${name}(:\\w+)?\\}|${name}\\b)`).test(query.rawSql)) {
+        continue;
+      }
+
+      let values: string[] = [];
+      templateSrv.replace(`\${${name}}`, scopedVars, (value: string | string[]) => {
+        values = Array.isArray(value) ? value : [value];
+        return '';
+      });
+
+      variables[name] = { values, multi: 'multi' in variable && Boolean(variable.multi || variable.includeAll) };
+    }
+
+    const adhocFilters = (filters ?? []).map(({ key, operator, value }) => ({
+      key,
+      operator,
+      value,
+      type: this.tagTypes?.[key],
+    }));
+
+    return { ...query, variables, adhocFilters };
+  }
+
+  /**
+   * Loads the types of the ad-hoc filter keys before the first query with
+   * filters, so their values are formatted with the type of their field
+   */
+  query(request: DataQueryRequest<SurrealQuery>): Observable<DataQueryResponse> {
+    if (this.tagTypes || !request.filters?.length) {
+      return super.query(request);
+    }
+
+    return from(this.getTagKeys().catch(() => [])).pipe(mergeMap(() => super.query(request)));
+  }
+
+  async getTagKeys(_?: DataSourceGetTagKeysOptions<SurrealQuery>): Promise<MetricFindValue[]> {
+    const keys: TagKey[] = await this.getResource('tag-keys');
+    this.tagTypes = Object.fromEntries(keys.map(({ text, type }) => [text, type ?? '']));
+    return keys.map(({ text }) => ({ text }));
+  }
+
+  async getTagValues(options: DataSourceGetTagValuesOptions<SurrealQuery>): Promise<MetricFindValue[]> {
+    const values: string[] = await this.getResource('tag-values', { key: options.key });
+    return values.map((text) => ({ text }));
+  }
+
+  getNamespaces(): Promise<string[]> {
+    return this.getResource('namespaces');
+  }
+
+  getDatabases(): Promise<string[]> {
+    return this.getResource('databases');
+  }
+
+  getTables(): Promise<string[]> {
+    return this.getResource('tables');
+  }
+
+  getFields(table: string): Promise<SurrealField[]> {
+    return this.getResource(`tables/${encodeURIComponent(table)}/fields`);
+  }
+
+  getIndexes(table: string): Promise<SurrealIndex[]> {
+    return this.getResource(`tables/${encodeURIComponent(table)}/indexes`);
+  }
 }
@@ -1,12 +1,63 @@
 import { DataSourceJsonData } from '@grafana/data';
 import { DataQuery } from '@grafana/schema';
 
+export type QueryFormat = 'table' | 'time_series' | 'graph';
+
+export type ArrayMode = 'json' | 'join' | 'explode';
+
+export type AuthMode = 'root' | 'namespace' | 'database' | 'record' | 'token' | 'anonymous';
+
 export interface SurrealQuery extends DataQuery {
   rawSql: string;
+  format?: QueryFormat;
+  live?: boolean;
+  variables?: Record<string, TemplateVariable>;
+  adhocFilters?: AdhocFilter[];
+  queryTimeout?: number;
+  maxRows?: number;
+  flattenDepth?: number;
+  arrayMode?: ArrayMode;
+  splitRecordIds?: boolean;
+  keepEmptyResults?: boolean;
+  nodeTitle?: string;
+  nodeSubtitle?: string;
+  nodeMainStat?: string;
+}
+
+/**
+ * An ad-hoc filter of the dashboard, added to the WHERE clause of the SELECT statements by the backend
+ */
+export interface AdhocFilter {
+  key: string;
+  operator: string;
+  value: string;
+  type?: ValueType;
+}
+
+/**
+ * The type of the values of a variable reference or an ad-hoc filter, which are strings when empty
+ */
+export type ValueType = '' | 'number' | 'record';
+
+/**
+ * A key of ad-hoc filters, with the type of the values of its field
+ */
+export interface TagKey {
+  text: string;
+  type?: ValueType;
+}
+
+/**
+ * The value of a dashboard variable, formatted as a SurrealQL literal by the backend
+ */
+export interface TemplateVariable {
+  values: string[];
+  multi?: boolean;
 }
 
 export const DEFAULT_QUERY: Partial<SurrealQuery> = {
   rawSql: 'SELECT * FROM surreal LIMIT 10',
+  format: 'table',
 };
 
 /**
@@ -18,6 +69,17 @@ export interface SurrealDataSourceOptions extends DataSourceJsonData {
   namespace?: string;
   scope?: string;
   username?: string;
+  authMode?: AuthMode;
+  access?: string;
+  tlsSkipVerify?: boolean;
+  serverName?: string;
+  maxConcurrentQueries?: number;
+  minConnections?: number;
+  maxConnections?: number;
+  connIdleTimeout?: number;
+  connMaxLifetime?: number;
+  queryTimeout?: number;
+  maxRows?: number;
 }
 
 /**
@@ -25,4 +87,29 @@ export interface SurrealDataSourceOptions extends DataSourceJsonData {
  */
 export interface SurrealSecureJsonData {
   password?: string;
+  token?: string;
+  /**
+   * The signin variables of record access, as a JSON object
+   */
+  accessVariables?: string;
+  tlsCACert?: string;
+  tlsClientCert?: string;
+  tlsClientKey?: string;
+}
+
+/**
+ * A field defined on a table, as returned by the `tables/{name}/fields` resource
+ */
+export interface SurrealField {
+  name: string;
+  type?: string;
+  definition: string;
+}
+
+/**
+ * An index defined on a table, as returned by the `tables/{name}/indexes` resource
+ */
+export interface SurrealIndex {
+  name: string;
+  definition: string;
 }
@@ -0,0 +1,18 @@
+import { StandardVariableQuery, StandardVariableSupport } from '@grafana/data';
+
+import type { DataSource } from './datasource';
+import type { SurrealQuery } from './types';
+
+/**
+ * Runs dashboard variable queries through the backend, which returns the
+ * `text` and `value` fields expected by dashboard variables
+ */
+export class SurrealVariableSupport extends StandardVariableSupport<DataSource> {
+  toDataQuery(query: StandardVariableQuery): SurrealQuery {
+    return {
+      refId: 'SurrealDB-Variable',
+      rawSql: query.query,
+      queryType: 'variable',
+    };
+  }
+}
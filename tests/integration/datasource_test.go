@@ -10,7 +10,6 @@ import (
 	"github.com/grafana-labs/surrealdb-datasource/pkg/plugin"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
-	"github.com/grafana/grafana-plugin-sdk-go/experimental/slo"
 )
 
 // table is a list of test cases for the QueryData method.
@@ -130,7 +129,7 @@ func TestCheckHealth(t *testing.T) {
 		t.Errorf("unexpected error: %s", err)
 	}
 
-	res, err := (*instance.(*slo.MetricsWrapper)).CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	res, err := instance.(*plugin.Instance).CheckHealth(context.Background(), &backend.CheckHealthRequest{})
 
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
@@ -164,7 +163,7 @@ func TestQueryData_Success(t *testing.T) {
 				Queries: dqs,
 			}
 
-			res, err := (*instance.(*slo.MetricsWrapper)).QueryData(context.Background(), &req)
+			res, err := instance.(*plugin.Instance).QueryData(context.Background(), &req)
 
 			if err != nil {
 				t.Errorf("unexpected error: %s", err)
@@ -210,7 +209,7 @@ func TestQueryData_BadTableName(t *testing.T) {
 		Queries: dqs,
 	}
 
-	res, err := (*instance.(*slo.MetricsWrapper)).QueryData(context.Background(), &req)
+	res, err := instance.(*plugin.Instance).QueryData(context.Background(), &req)
 
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
@@ -246,7 +245,7 @@ func TestQueryData_BadQuerySyntax(t *testing.T) {
 		Queries: dqs,
 	}
 
-	res, err := (*instance.(*slo.MetricsWrapper)).QueryData(context.Background(), &req)
+	res, err := instance.(*plugin.Instance).QueryData(context.Background(), &req)
 
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)